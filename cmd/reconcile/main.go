@@ -0,0 +1,37 @@
+// Command reconcile recomputes denormalized card data from the source tables.
+// Run it after manual database edits or if counters or ratings ever drift from the
+// votes and comments tables.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	_ "github.com/lib/pq"
+
+	"bugtracker/internal/config"
+	"bugtracker/internal/repository"
+)
+
+func main() {
+	cfg := config.Load()
+
+	db, err := sql.Open("postgres", cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal("Failed to open database:", err)
+	}
+	defer db.Close()
+
+	if err := db.Ping(); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	repo := repository.New(db)
+
+	fixed, err := repo.ReconcileCards(context.Background())
+	if err != nil {
+		log.Fatal("Failed to reconcile cards:", err)
+	}
+	log.Printf("Reconciled cards: %d cards fixed", fixed)
+}
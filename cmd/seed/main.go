@@ -0,0 +1,122 @@
+// Command seed fills a migrated database with generated users, cards, tags, votes and
+// comments, for measuring queries on a realistic amount of data:
+//
+//	go run ./cmd/seed -cards 100000
+//
+// Seeded users have negative IDs, which Telegram never assigns. Every run adds new cards;
+// the counters, ratings and ranking scores of the cards are computed as cmd/reconcile does.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"log"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"bugtracker/internal/config"
+	"bugtracker/internal/repository"
+)
+
+func main() {
+	cards := flag.Int("cards", 100000, "number of cards to add")
+	users := flag.Int("users", 5000, "number of seeded users voting and commenting")
+	votes := flag.Int("votes", 20, "average number of votes per card")
+	comments := flag.Int("comments", 5, "average number of comments per card")
+	flag.Parse()
+	if *cards < 1 || *users < 1 || *votes < 0 || *comments < 0 {
+		log.Fatal("-cards and -users must be positive, -votes and -comments not negative")
+	}
+
+	cfg := config.Load()
+
+	db, err := sql.Open("postgres", cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal("Failed to open database:", err)
+	}
+	defer db.Close()
+
+	if err := db.Ping(); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	ctx := context.Background()
+	var lastID int64
+	if err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM cards").Scan(&lastID); err != nil {
+		log.Fatal("Failed to read cards:", err)
+	}
+
+	steps := []struct {
+		name  string
+		query string
+		args  []interface{}
+	}{
+		{"users", `
+			INSERT INTO users (id, first_name, username, auth_date, created_at)
+			SELECT -g, 'Seed user ' || g, 'seed_' || g, NOW(), NOW() - INTERVAL '400 days'
+			FROM generate_series(1, $1::int) g
+			ON CONFLICT (id) DO NOTHING
+		`, []interface{}{*users}},
+		{"cards", `
+			INSERT INTO cards (user_id, title, description, type, status, created_at, updated_at)
+			SELECT -(1 + floor(random() * $2::int))::bigint,
+			       (ARRAY['Crash', 'Slow', 'Login', 'Sync', 'Theme', 'Search', 'Export', 'Upload'])[1 + floor(random() * 8)::int] || ' problem ' || g,
+			       'Generated description ' || md5(g::text),
+			       CASE WHEN random() < 0.6 THEN 'issue' ELSE 'suggestion' END,
+			       (ARRAY['open', 'closed', 'fixed', 'fix_coming'])[1 + floor(random() * 4)::int],
+			       NOW() - random() * INTERVAL '365 days', NOW()
+			FROM generate_series(1, $1::int) g
+		`, []interface{}{*cards, *users}},
+		{"tags", `
+			INSERT INTO tags (name)
+			SELECT unnest(ARRAY['android', 'ios', 'web', 'desktop', 'api', 'ui'])
+			ON CONFLICT (name) DO NOTHING
+		`, nil},
+		{"card tags", `
+			INSERT INTO card_tags (card_id, tag_id)
+			SELECT c.id, t.id FROM cards c
+			JOIN tags t ON t.name = (ARRAY['android', 'ios', 'web', 'desktop', 'api', 'ui'])[1 + (hashtext(c.id::text) & 2147483647) % 6]
+			WHERE c.id > $1
+			ON CONFLICT DO NOTHING
+		`, []interface{}{lastID}},
+		// Voters and commenters are picked by hashing, so a card gets between none and
+		// twice the average; repeated voters are skipped
+		{"votes", `
+			INSERT INTO votes (user_id, card_id, value, created_at)
+			SELECT -(1 + (hashtext(c.id || ':' || g) & 2147483647) % $2::int), c.id,
+			       CASE WHEN random() < 0.75 THEN 1 ELSE -1 END, c.created_at
+			FROM cards c, generate_series(1, (hashtext('votes' || c.id) & 2147483647) % ($3::int * 2 + 1)) g
+			WHERE c.id > $1
+			ON CONFLICT DO NOTHING
+		`, []interface{}{lastID, *users, *votes}},
+		{"comments", `
+			INSERT INTO comments (card_id, user_id, content, is_internal, created_at)
+			SELECT c.id, -(1 + (hashtext(c.id || '/' || g) & 2147483647) % $2::int),
+			       'Generated comment ' || g, random() < 0.1, c.created_at + g * INTERVAL '1 hour'
+			FROM cards c, generate_series(1, (hashtext('comments' || c.id) & 2147483647) % ($3::int * 2 + 1)) g
+			WHERE c.id > $1
+		`, []interface{}{lastID, *users, *comments}},
+	}
+	for _, step := range steps {
+		start := time.Now()
+		res, err := db.ExecContext(ctx, step.query, step.args...)
+		if err != nil {
+			log.Fatalf("Failed to seed %s: %v", step.name, err)
+		}
+		n, _ := res.RowsAffected()
+		log.Printf("Seeded %s: %d rows in %s", step.name, n, time.Since(start).Round(time.Millisecond))
+	}
+
+	start := time.Now()
+	fixed, err := repository.New(db).ReconcileCards(ctx)
+	if err != nil {
+		log.Fatal("Failed to reconcile cards:", err)
+	}
+	log.Printf("Computed counters and scores of %d cards in %s", fixed, time.Since(start).Round(time.Millisecond))
+
+	if _, err := db.ExecContext(ctx, "ANALYZE"); err != nil {
+		log.Fatal("Failed to analyze:", err)
+	}
+}
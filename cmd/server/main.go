@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -24,7 +28,6 @@ func main() {
 	if err != nil {
 		log.Fatal("Failed to open database:", err)
 	}
-	defer db.Close()
 
 	if err := db.Ping(); err != nil {
 		log.Fatal("Failed to connect to database:", err)
@@ -33,10 +36,45 @@ func main() {
 	if err := migrate.Run(db, "./migrations"); err != nil {
 		log.Fatal("Failed to run migrations:", err)
 	}
+	db.Close()
+
+	// Migrations may run longer than the statement timeout, so it only applies from here on
+	db, err = sql.Open("postgres", cfg.ServerDatabaseURL())
+	if err != nil {
+		log.Fatal("Failed to open database:", err)
+	}
+	defer db.Close()
+
+	var replica *sql.DB
+	if cfg.DatabaseReplicaURL != "" {
+		replica, err = sql.Open("postgres", cfg.ServerReplicaURL())
+		if err != nil {
+			log.Fatal("Failed to open replica database:", err)
+		}
+		defer replica.Close()
+	}
+
+	// Canceled on SIGTERM or Ctrl+C, which shut the server down
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
 
-	repo := repository.New(db)
+	repo := repository.NewWithReplica(db, replica)
+	if replica != nil {
+		// An unreachable replica is logged and reads go to the primary until it answers
+		if err := repo.CheckReplica(ctx); err == nil {
+			log.Println("Reading lists, search and comments from the replica")
+		}
+		go repo.WatchReplica(ctx, 10*time.Second)
+	}
 	h := handlers.New(repo, cfg)
 
+	// Delivers the Telegram notifications queued by the handlers
+	notifierDone := make(chan struct{})
+	go func() {
+		defer close(notifierDone)
+		h.RunNotifier(ctx)
+	}()
+
 	app := fiber.New(fiber.Config{
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			log.Printf("Error: %v", err)
@@ -50,25 +88,12 @@ func main() {
 		AllowOrigins:     "http://localhost:5173,http://localhost:3000",
 		AllowCredentials: true,
 	}))
+	app.Use(h.TimeoutMiddleware)
+	app.Use(h.ReadYourWritesMiddleware)
 	app.Use(h.AuthMiddleware)
 
 	// JSON API routes
-	api := app.Group("/api")
-	api.Get("/config", h.GetConfig)
-	api.Get("/auth/me", h.GetMe)
-	api.Post("/auth/telegram", h.APITelegramAuth)
-	api.Post("/auth/logout", h.APILogout)
-	api.Get("/cards", h.GetCards)
-	api.Get("/cards/:id", h.GetCard)
-	api.Post("/cards", h.APICreateCard)
-	api.Delete("/cards/:id", h.APIDeleteCard)
-	api.Patch("/cards/:id/status", h.APIUpdateCardStatus)
-	api.Post("/cards/:id/vote", h.APIVote)
-	api.Get("/cards/:id/comments", h.GetComments)
-	api.Post("/cards/:id/comments", h.APICreateComment)
-	api.Delete("/comments/:id", h.APIDeleteComment)
-	api.Post("/upload", h.APIUploadFile)
-	api.Post("/upload/image", h.APIUploadImage) // Legacy endpoint for ImgBB
+	h.Routes(app.Group("/api"))
 
 	// Serve React SPA from web/dist
 	distPath := "./web/dist"
@@ -87,6 +112,20 @@ func main() {
 		})
 	}
 
+	go func() {
+		<-ctx.Done()
+		log.Println("Shutting down")
+		if err := app.ShutdownWithTimeout(cfg.RequestTimeout); err != nil {
+			log.Printf("Failed to shut down the server: %v", err)
+		}
+	}()
+
 	log.Printf("Server starting on http://localhost:%s", cfg.Port)
-	log.Fatal(app.Listen(":" + cfg.Port))
+	err = app.Listen(":" + cfg.Port)
+	// The notifier may be delivering; the database is closed once it has stopped
+	stop()
+	<-notifierDone
+	if err != nil {
+		log.Fatal(err)
+	}
 }
@@ -10,15 +10,15 @@ export function useCards(params: {
 }) {
   return useInfiniteQuery({
     queryKey: ['cards', params],
-    queryFn: ({ pageParam = 1 }) =>
+    queryFn: ({ pageParam }) =>
       api.getCards({
         ...params,
-        page: pageParam,
-        limit: pageParam === 1 ? 100 : 20,
+        cursor: pageParam,
+        limit: pageParam ? 20 : 100,
       }),
-    initialPageParam: 1,
-    getNextPageParam: (lastPage, _allPages, lastPageParam) =>
-      lastPage.has_more ? lastPageParam + 1 : undefined,
+    initialPageParam: undefined as string | undefined,
+    getNextPageParam: (lastPage) =>
+      lastPage.has_more ? lastPage.next_cursor : undefined,
   });
 }
 
@@ -63,7 +63,7 @@ export function CommentItem({ comment, cardId, isAdmin = false }: CommentItemPro
   };
 
   return (
-    <Box p="sm" bg="var(--mantine-color-body)" style={{ borderRadius: 12 }}>
+    <Box id={`comment-${comment.id}`} p="sm" bg="var(--mantine-color-body)" style={{ borderRadius: 12 }}>
       <Group align="flex-start" gap="sm" wrap="nowrap">
         <Avatar
           src={author?.photo_url}
@@ -1,4 +1,5 @@
-import { useState } from 'react';
+import { useEffect, useMemo, useState } from 'react';
+import { useLocation } from 'react-router-dom';
 import { Stack, Text, Group, Textarea, Button, Box, FileButton, ActionIcon, Image } from '@mantine/core';
 import { notifications } from '@mantine/notifications';
 import { IconPlus, IconX, IconPlayerPlay, IconFile } from '@tabler/icons-react';
@@ -10,12 +11,101 @@ import type { Comment, Attachment } from '@/shared/types';
 
 interface CommentListProps {
   cardId: number;
+  // The latest comments, oldest first, as embedded in the card
   comments: Comment[];
+  total: number;
+  hasOlder: boolean;
   isAdmin?: boolean;
 }
 
-export function CommentList({ cardId, comments, isAdmin = false }: CommentListProps) {
+const OLDER_COMMENTS_LIMIT = 50;
+
+// mergeComments joins lists of comments into one ordered by ID, without duplicates
+function mergeComments(...lists: Comment[][]): Comment[] {
+  const byId = new Map<number, Comment>();
+  for (const list of lists) {
+    for (const comment of list) byId.set(comment.id, comment);
+  }
+  return [...byId.values()].sort((a, b) => a.id - b.id);
+}
+
+export function CommentList({ cardId, comments: latest, total, hasOlder, isAdmin = false }: CommentListProps) {
   const { user, openLoginModal } = useAuth();
+  // Comments loaded before the latest ones, oldest first
+  const [older, setOlder] = useState<Comment[]>([]);
+  const [olderHasMore, setOlderHasMore] = useState(hasOlder);
+  const [loadingOlder, setLoadingOlder] = useState(false);
+  const comments = useMemo(() => mergeComments(older, latest), [older, latest]);
+
+  useEffect(() => {
+    if (older.length === 0) setOlderHasMore(hasOlder);
+  }, [older.length, hasOlder]);
+
+  // When the latest comments move on (e.g. after posting one), comments that dropped out
+  // of them would be missing between the loaded older ones and the new latest ones
+  useEffect(() => {
+    if (older.length === 0 || latest.length === 0) return;
+    const newestOlder = older[older.length - 1].id;
+    if (latest[0].id <= newestOlder) return;
+    let cancelled = false;
+    api
+      .getComments(cardId, { after: newestOlder, limit: OLDER_COMMENTS_LIMIT })
+      .then((page) => {
+        if (!cancelled) setOlder((prev) => mergeComments(prev, page.comments));
+      })
+      .catch(() => {});
+    return () => {
+      cancelled = true;
+    };
+  }, [cardId, older, latest]);
+
+  // Links to a comment (/c/:id#comment-N, from notifications and search results) load
+  // the older comments up to it, then scroll to it
+  const { hash } = useLocation();
+  const targetId = Number(/^#comment-(\d+)$/.exec(hash)?.[1] ?? 0);
+  const [scrolledTo, setScrolledTo] = useState(0);
+
+  useEffect(() => {
+    if (!targetId || !olderHasMore || comments.length === 0 || targetId >= comments[0].id) return;
+    let cancelled = false;
+    api
+      .getComments(cardId, { before: targetId + 1, limit: OLDER_COMMENTS_LIMIT })
+      .then((page) => {
+        if (cancelled) return;
+        // The comments between it and the latest ones are filled in by the effect above
+        setOlder((prev) => mergeComments(page.comments, prev));
+        setOlderHasMore(page.has_more);
+      })
+      .catch(() => {});
+    return () => {
+      cancelled = true;
+    };
+  }, [cardId, targetId, olderHasMore, comments]);
+
+  useEffect(() => {
+    if (!targetId || scrolledTo === targetId || !comments.some((c) => c.id === targetId)) return;
+    document.getElementById(`comment-${targetId}`)?.scrollIntoView({ block: 'center' });
+    setScrolledTo(targetId);
+  }, [targetId, scrolledTo, comments]);
+
+  const handleLoadOlder = async () => {
+    if (comments.length === 0) return;
+    setLoadingOlder(true);
+    try {
+      const page = await api.getComments(cardId, { before: comments[0].id, limit: OLDER_COMMENTS_LIMIT });
+      setOlder((prev) => mergeComments(page.comments, prev));
+      setOlderHasMore(page.has_more);
+    } catch (error) {
+      notifications.show({
+        title: 'Error',
+        message: error instanceof Error ? error.message : 'Failed to load comments',
+        color: 'red',
+      });
+    } finally {
+      setLoadingOlder(false);
+    }
+  };
+
   const [content, setContent] = useState('');
   const [attachments, setAttachments] = useState<Attachment[]>([]);
   const [uploading, setUploading] = useState(false);
@@ -80,11 +170,16 @@ export function CommentList({ cardId, comments, isAdmin = false }: CommentListPr
       <Group gap="xs" mb="md">
         <Text fw={500}>Comments</Text>
         <Text size="sm" c="dimmed">
-          {comments.length}
+          {Math.max(total, comments.length)}
         </Text>
       </Group>
 
       <Stack gap="sm" mb="md">
+        {olderHasMore && comments.length > 0 && (
+          <Button variant="subtle" size="xs" onClick={handleLoadOlder} loading={loadingOlder}>
+            Load older comments ({Math.max(total - comments.length, 0)})
+          </Button>
+        )}
         {comments.map((comment) => (
           <CommentItem key={comment.id} comment={comment} cardId={cardId} isAdmin={isAdmin} />
         ))}
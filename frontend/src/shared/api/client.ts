@@ -1,4 +1,4 @@
-import type { Card, CardsResponse, CardDetailResponse, Comment, User, TelegramAuthData, SortType, CardType, Attachment } from '@/shared/types';
+import type { Card, CardsResponse, CardDetailResponse, Comment, CommentsResponse, User, TelegramAuthData, SortType, CardType, Attachment } from '@/shared/types';
 
 const API_BASE = '/api';
 
@@ -54,6 +54,7 @@ export const api = {
     type?: CardType;
     status?: string;
     page?: number;
+    cursor?: string;
     limit?: number;
     query?: string;
   }): Promise<CardsResponse> {
@@ -62,6 +63,7 @@ export const api = {
     if (params.type) searchParams.set('type', params.type);
     if (params.status) searchParams.set('status', params.status);
     if (params.page) searchParams.set('page', params.page.toString());
+    if (params.cursor) searchParams.set('cursor', params.cursor);
     if (params.limit) searchParams.set('limit', params.limit.toString());
     if (params.query) searchParams.set('query', params.query);
 
@@ -92,8 +94,17 @@ export const api = {
   },
 
   // Comments
-  async getComments(cardId: number): Promise<Comment[]> {
-    return request(`/cards/${cardId}/comments`);
+  async getComments(cardId: number, params: {
+    before?: number;
+    after?: number;
+    limit?: number;
+  } = {}): Promise<CommentsResponse> {
+    const searchParams = new URLSearchParams();
+    if (params.before) searchParams.set('before', params.before.toString());
+    if (params.after) searchParams.set('after', params.after.toString());
+    if (params.limit) searchParams.set('limit', params.limit.toString());
+
+    return request(`/cards/${cardId}/comments?${searchParams.toString()}`);
   },
 
   async createComment(cardId: number, content: string, images?: string[]): Promise<Comment> {
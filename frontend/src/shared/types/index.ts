@@ -36,13 +36,25 @@ export interface Comment {
 
 export interface CardsResponse {
   cards: Card[];
-  total: number;
+  total?: number;
+  total_estimated?: boolean;
   has_more: boolean;
+  next_cursor?: string;
 }
 
 export interface CardDetailResponse {
   card: Card;
+  official_responses?: Comment[];
+  // The latest comments, oldest first; older ones are loaded with getComments
+  comments: Comment[];
+  comments_total: number;
+  comments_has_more: boolean;
+}
+
+export interface CommentsResponse {
   comments: Comment[];
+  total: number;
+  has_more: boolean;
 }
 
 export interface TelegramAuthData {
@@ -261,7 +261,14 @@ export function CardModal() {
             </Group>
           )}
 
-          <CommentList cardId={data.card.id} comments={data.comments || []} isAdmin={isAdmin} />
+          <CommentList
+            key={data.card.id}
+            cardId={data.card.id}
+            comments={data.comments || []}
+            total={data.comments_total ?? 0}
+            hasOlder={data.comments_has_more ?? false}
+            isAdmin={isAdmin}
+          />
         </Stack>
       )}
 
@@ -1,9 +1,11 @@
 package config
 
 import (
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -24,6 +26,40 @@ type Config struct {
 	S3AccessKeyID     string
 	S3SecretAccessKey string
 	S3PublicURL       string
+	// Request deadline, and PostgreSQL statement_timeout for the server's queries; 0 disables
+	RequestTimeout     time.Duration
+	DBStatementTimeout time.Duration
+	// Optional streaming replica for read-only queries, and how long a client's reads
+	// stay on the primary after it wrote, to see its own changes despite replication lag
+	DatabaseReplicaURL string
+	ReplicaStickiness  time.Duration
+	// Emoji allowed as reactions on cards and comments
+	ReactionEmojis []string
+	// Duplicate suggestions while writing a card (see repository.SimilarCardsOptions)
+	SimilarMinSimilarity float64
+	SimilarMinRank       float64
+	SimilarLimit         int
+	// Whether everyone, not only staff, can see who voted on a card. There are no
+	// projects: an instance is one project, so the setting covers all its cards.
+	PublicVoters bool
+	// Upvotes per user and period on suggestions; 0 disables budgets. The period is a
+	// calendar day, week (from Monday) or month in VoteBudgetTimezone.
+	VoteBudget         int
+	VoteBudgetPeriod   string
+	VoteBudgetTimezone string
+	// Vote manipulation heuristics (see repository.VoteRules)
+	VoteMinAccountAge   time.Duration
+	VoteNewAccountAge   time.Duration
+	VoteUserBurstLimit  int
+	VoteUserBurstWindow time.Duration
+	VoteCardBurstLimit  int
+	VoteCardBurstWindow time.Duration
+	// Notification delivery from the outbox: how often to look for due notifications, and
+	// retries with a delay doubling from NotifyRetryBase up to NotifyRetryMax
+	NotifyPollInterval time.Duration
+	NotifyMaxAttempts  int
+	NotifyRetryBase    time.Duration
+	NotifyRetryMax     time.Duration
 }
 
 func Load() *Config {
@@ -44,6 +80,33 @@ func Load() *Config {
 		S3AccessKeyID:     getEnv("S3_ACCESS_KEY_ID", ""),
 		S3SecretAccessKey: getEnv("S3_SECRET_ACCESS_KEY", ""),
 		S3PublicURL:       getEnv("S3_PUBLIC_URL", ""),
+
+		RequestTimeout:     getEnvDuration("REQUEST_TIMEOUT", 30*time.Second),
+		DBStatementTimeout: getEnvDuration("DB_STATEMENT_TIMEOUT", 10*time.Second),
+		DatabaseReplicaURL: getEnv("DATABASE_REPLICA_URL", ""),
+		ReplicaStickiness:  getEnvDuration("DB_REPLICA_STICKINESS", 10*time.Second),
+
+		ReactionEmojis: parseList(getEnv("REACTION_EMOJIS", "👍,👎,❤️,🎉,😄,😕,👀,🚀")),
+		PublicVoters:   getEnvBool("PUBLIC_VOTERS", false),
+
+		SimilarMinSimilarity: getEnvFloat("SIMILAR_MIN_SIMILARITY", 0.3),
+		SimilarMinRank:       getEnvFloat("SIMILAR_MIN_RANK", 0.1),
+		SimilarLimit:         getEnvInt("SIMILAR_LIMIT", 5),
+
+		VoteBudget:          getEnvInt("VOTE_BUDGET", 0),
+		VoteBudgetPeriod:    getEnv("VOTE_BUDGET_PERIOD", "month"),
+		VoteBudgetTimezone:  getEnv("VOTE_BUDGET_TIMEZONE", "UTC"),
+		VoteMinAccountAge:   getEnvDuration("VOTE_MIN_ACCOUNT_AGE", time.Hour),
+		VoteNewAccountAge:   getEnvDuration("VOTE_NEW_ACCOUNT_AGE", 7*24*time.Hour),
+		VoteUserBurstLimit:  getEnvInt("VOTE_USER_BURST_LIMIT", 10),
+		VoteUserBurstWindow: getEnvDuration("VOTE_USER_BURST_WINDOW", time.Minute),
+		VoteCardBurstLimit:  getEnvInt("VOTE_CARD_BURST_LIMIT", 10),
+		VoteCardBurstWindow: getEnvDuration("VOTE_CARD_BURST_WINDOW", 10*time.Second),
+
+		NotifyPollInterval: getEnvDuration("NOTIFY_POLL_INTERVAL", 5*time.Second),
+		NotifyMaxAttempts:  getEnvInt("NOTIFY_MAX_ATTEMPTS", 8),
+		NotifyRetryBase:    getEnvDuration("NOTIFY_RETRY_BASE", 30*time.Second),
+		NotifyRetryMax:     getEnvDuration("NOTIFY_RETRY_MAX", time.Hour),
 	}
 }
 
@@ -54,6 +117,34 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
 func parseAdminIDs(s string) []int64 {
 	var ids []int64
 	for _, part := range strings.Split(s, ",") {
@@ -68,6 +159,54 @@ func parseAdminIDs(s string) []int64 {
 	return ids
 }
 
+func parseList(s string) []string {
+	var items []string
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			items = append(items, part)
+		}
+	}
+	return items
+}
+
+// ServerDatabaseURL is DatabaseURL with the statement timeout applied to every
+// connection. Migrations and maintenance commands use DatabaseURL without it.
+func (c *Config) ServerDatabaseURL() string {
+	return c.withStatementTimeout(c.DatabaseURL)
+}
+
+// ServerReplicaURL is DatabaseReplicaURL with the statement timeout, empty without a replica
+func (c *Config) ServerReplicaURL() string {
+	if c.DatabaseReplicaURL == "" {
+		return ""
+	}
+	return c.withStatementTimeout(c.DatabaseReplicaURL)
+}
+
+func (c *Config) withStatementTimeout(dsn string) string {
+	if c.DBStatementTimeout <= 0 {
+		return dsn
+	}
+	ms := strconv.FormatInt(c.DBStatementTimeout.Milliseconds(), 10)
+	if u, err := url.Parse(dsn); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		q := u.Query()
+		q.Set("statement_timeout", ms)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return dsn + " statement_timeout=" + ms
+}
+
+func (c *Config) IsAllowedReaction(emoji string) bool {
+	for _, e := range c.ReactionEmojis {
+		if e == emoji {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Config) IsAdmin(userID int64) bool {
 	for _, id := range c.AdminIDs {
 		if id == userID {
@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
 	"log"
 	"strconv"
 	"strings"
@@ -11,15 +11,23 @@ import (
 
 	"bugtracker/internal/auth"
 	"bugtracker/internal/models"
+	"bugtracker/internal/repository"
 	s3client "bugtracker/internal/s3"
+	"bugtracker/internal/search"
 )
 
 // API Handlers for React frontend
 
+const (
+	defaultCommentsLimit = 50
+	maxCommentsLimit     = 200
+)
+
 // GetConfig returns public configuration
 func (h *Handler) GetConfig(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
-		"bot_username": h.cfg.BotUsername,
+		"bot_username":    h.cfg.BotUsername,
+		"reaction_emojis": h.cfg.ReactionEmojis,
 	})
 }
 
@@ -33,6 +41,12 @@ func (h *Handler) GetMe(c *fiber.Ctx) error {
 	return c.JSON(user)
 }
 
+// isStaff reports whether the current user is staff (an admin)
+func (h *Handler) isStaff(c *fiber.Ctx) bool {
+	user, ok := c.Locals("user").(*models.User)
+	return ok && user != nil && h.cfg.IsAdmin(user.ID)
+}
+
 // APILogout handles logout for JSON API
 func (h *Handler) APILogout(c *fiber.Ctx) error {
 	c.Cookie(&fiber.Cookie{
@@ -44,9 +58,21 @@ func (h *Handler) APILogout(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"ok": true})
 }
 
-// GetCards returns paginated list of cards as JSON
+// GetCards returns paginated list of cards as JSON. The query parameter accepts
+// the search box syntax of package search; syntax errors are reported with positions.
+// With facets=true the response also counts matching cards per status, type and tag.
+//
+// Pages are continued with cursor=<next_cursor of the previous page>, which keeps
+// the position when cards move while scrolling; page=N is still accepted. The total
+// is exact by default for page=N and skipped for cursors; total=exact|estimate|none
+// overrides that.
+//
+// Responses carry an ETag; a request with a matching If-None-Match gets 304.
 func (h *Handler) GetCards(c *fiber.Ctx) error {
 	sort := c.Query("sort", "rate")
+	if !repository.ValidSort(sort) {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid sort"})
+	}
 	cardType := c.Query("type")
 	status := c.Query("status")
 	query := c.Query("query")
@@ -58,47 +84,181 @@ func (h *Handler) GetCards(c *fiber.Ctx) error {
 	if limit < 1 || limit > 100 {
 		limit = 20
 	}
-	offset := (page - 1) * limit
+	cardPage := repository.CardPage{Limit: limit, Offset: (page - 1) * limit}
+
+	if token := c.Query("cursor"); token != "" {
+		cursor, err := repository.DecodeCardCursor(token)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid cursor"})
+		}
+		cardPage.After = cursor
+		cardPage.Total = repository.TotalNone
+	}
+	switch c.Query("total") {
+	case "exact":
+		cardPage.Total = repository.TotalExact
+	case "estimate":
+		cardPage.Total = repository.TotalEstimate
+	case "none":
+		cardPage.Total = repository.TotalNone
+	}
 
 	var userID int64
 	if user, ok := c.Locals("user").(*models.User); ok && user != nil {
 		userID = user.ID
 	}
 
-	cards, total, err := h.repo.ListCardsWithSearch(sort, cardType, status, query, limit, offset, userID)
+	tags := cleanTags(strings.Split(c.Query("tags"), ","))
+
+	filter, ok, err := cardFilter(cardType, status, query, tags)
+	var syntaxErr *search.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid query: " + syntaxErr.Message, "query_error": syntaxErr})
+	}
+	if !ok {
+		resp := fiber.Map{"cards": []*models.Card{}, "total": 0, "has_more": false}
+		if c.QueryBool("facets") {
+			resp["facets"] = &models.CardFacets{Statuses: []models.FacetCount{}, Types: []models.FacetCount{}, Tags: []models.FacetCount{}}
+		}
+		return c.JSON(resp)
+	}
+
+	version, err := h.repo.CardsVersion(c.UserContext())
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Error loading cards"})
+		return h.dbError(c, err, "Error loading cards")
+	}
+	tag := h.etag(c, version, userID)
+	if notModified(c, tag, userID) {
+		return c.SendStatus(fiber.StatusNotModified)
 	}
 
-	hasMore := offset+len(cards) < total
+	cards, total, next, err := h.repo.ListCards(c.UserContext(), filter, sort, cardPage, userID, h.isStaff(c))
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid cursor"})
+	}
+	if err != nil {
+		return h.dbError(c, err, "Error loading cards")
+	}
+	if cards == nil {
+		cards = []*models.Card{}
+	}
+	if err := h.attachCardReactions(c.UserContext(), cards, userID); err != nil {
+		return h.dbError(c, err, "Error loading cards")
+	}
 
-	return c.JSON(fiber.Map{
+	resp := fiber.Map{
 		"cards":    cards,
-		"total":    total,
-		"has_more": hasMore,
-	})
+		"has_more": next != nil,
+	}
+	if next != nil {
+		resp["next_cursor"] = next.Encode()
+	}
+	switch cardPage.Total {
+	case repository.TotalExact:
+		resp["total"] = total
+	case repository.TotalEstimate:
+		resp["total"] = total
+		resp["total_estimated"] = true
+	}
+	if c.QueryBool("facets") {
+		facets, err := h.repo.CardFacets(c.UserContext(), filter)
+		if err != nil {
+			return h.dbError(c, err, "Error loading facets")
+		}
+		resp["facets"] = facets
+	}
+	setCacheHeaders(c, tag, userID)
+	return c.JSON(resp)
 }
 
-// GetCard returns single card with comments as JSON
+// GetCard returns single card with its latest comments as JSON.
+// Older comments are loaded through GetComments with before=<oldest comment id>.
+// Responses carry an ETag; a request with a matching If-None-Match gets 304.
 func (h *Handler) GetCard(c *fiber.Ctx) error {
 	id, _ := strconv.ParseInt(c.Params("id"), 10, 64)
-	card, err := h.repo.GetCard(id)
-	if err != nil || card == nil {
-		return c.Status(404).JSON(fiber.Map{"error": "Card not found"})
-	}
+	staff := h.isStaff(c)
 
 	var userID int64
 	if user, ok := c.Locals("user").(*models.User); ok && user != nil {
 		userID = user.ID
-		card.UserVote, _ = h.repo.GetUserVote(userID, card.ID)
 	}
 
-	comments, _ := h.repo.GetComments(id)
+	version, err := h.repo.CardVersion(c.UserContext(), id)
+	if err != nil {
+		return h.dbError(c, err, "Error loading card")
+	}
+	if version == "" {
+		return c.Status(404).JSON(fiber.Map{"error": "Card not found"})
+	}
+	tag := h.etag(c, version, userID)
+	if notModified(c, tag, userID) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	card, err := h.repo.GetCard(c.UserContext(), id, staff)
+	if err != nil {
+		return h.dbError(c, err, "Error loading card")
+	}
+	if card == nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Card not found"})
+	}
+	if userID != 0 {
+		if card.UserVote, err = h.repo.GetUserVote(c.UserContext(), userID, card.ID); err != nil {
+			return h.dbError(c, err, "Error loading card")
+		}
+	}
+
+	limit, _ := strconv.Atoi(c.Query("comments_limit", strconv.Itoa(defaultCommentsLimit)))
+	if limit < 1 || limit > maxCommentsLimit {
+		limit = defaultCommentsLimit
+	}
+	page := repository.CommentPage{Limit: limit, Latest: true}
+	comments, total, hasMore, err := h.repo.ListComments(c.UserContext(), id, page, staff)
+	if err != nil {
+		return h.dbError(c, err, "Error loading comments")
+	}
+	official, err := h.repo.GetOfficialResponses(c.UserContext(), id)
+	if err != nil {
+		return h.dbError(c, err, "Error loading comments")
+	}
+	if err := h.attachCardReactions(c.UserContext(), []*models.Card{card}, userID); err != nil {
+		return h.dbError(c, err, "Error loading reactions")
+	}
+	if err := h.attachCommentReactions(c.UserContext(), append(official, comments...), userID); err != nil {
+		return h.dbError(c, err, "Error loading reactions")
+	}
 
+	setCacheHeaders(c, tag, userID)
 	return c.JSON(fiber.Map{
-		"card":     card,
-		"comments": comments,
+		"card":               card,
+		"official_responses": official,
+		"comments":           comments,
+		"comments_total":     total,
+		"comments_has_more":  hasMore,
+	})
+}
+
+// GetSimilarCards suggests existing open cards that may duplicate a card being written
+func (h *Handler) GetSimilarCards(c *fiber.Ctx) error {
+	title := strings.TrimSpace(c.Query("title"))
+	if len([]rune(title)) < 3 {
+		return c.JSON(fiber.Map{"cards": []*models.SimilarCard{}})
+	}
+
+	cards, err := h.repo.FindSimilarCards(c.UserContext(), title, repository.SimilarCardsOptions{
+		Type:          c.Query("type"),
+		MinSimilarity: h.cfg.SimilarMinSimilarity,
+		MinRank:       h.cfg.SimilarMinRank,
+		Limit:         h.cfg.SimilarLimit,
 	})
+	if err != nil {
+		return h.dbError(c, err, "Error loading similar cards")
+	}
+	if cards == nil {
+		cards = []*models.SimilarCard{}
+	}
+
+	return c.JSON(fiber.Map{"cards": cards})
 }
 
 // APICreateCard creates a new card and returns JSON
@@ -138,9 +298,16 @@ func (h *Handler) APICreateCard(c *fiber.Ctx) error {
 		Author:      user,
 	}
 
-	if err := h.repo.CreateCard(card); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Error creating card"})
+	mentioned, err := h.mentionedUsers(c.UserContext(), card.Description, user, false)
+	if err != nil {
+		return h.dbError(c, err, "Error creating card")
+	}
+	notify := mentionNotifications(0, user, mentioned, 0) // CreateCard sets the card
+
+	if err := h.repo.CreateCard(c.UserContext(), card, mentioned, notify); err != nil {
+		return h.dbError(c, err, "Error creating card")
 	}
+	h.wakeNotifier()
 
 	return c.Status(201).JSON(card)
 }
@@ -166,33 +333,123 @@ func (h *Handler) APIVote(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid vote value"})
 	}
 
-	currentVote, _ := h.repo.GetUserVote(user.ID, cardID)
-	newValue := input.Value
-	if currentVote == input.Value {
-		newValue = 0
+	result, err := h.repo.ToggleVote(c.UserContext(), user.ID, cardID, input.Value, h.voteRules(), h.voteBudget())
+	if errors.Is(err, repository.ErrVoteBudgetExceeded) {
+		_, resetsAt := h.budgetPeriod(time.Now())
+		return c.Status(409).JSON(fiber.Map{"error": "No votes left in this period", "remaining_votes": 0, "resets_at": resetsAt})
+	}
+	if err != nil {
+		return h.dbError(c, err, "Error voting")
+	}
+	if result == nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Card not found"})
 	}
 
-	if err := h.repo.Vote(user.ID, cardID, newValue); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Error voting"})
+	card, err := h.repo.GetCard(c.UserContext(), cardID, h.isStaff(c))
+	if err != nil {
+		return h.dbError(c, err, "Error loading card")
+	}
+	if card == nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Card not found"})
+	}
+	card.UserVote = result.Value
+	card.Rating, card.Likes, card.Dislikes = result.Rating, result.Likes, result.Dislikes
+	if err := h.attachCardReactions(c.UserContext(), []*models.Card{card}, user.ID); err != nil {
+		return h.dbError(c, err, "Error loading reactions")
 	}
 
-	card, err := h.repo.GetCard(cardID)
-	if err != nil || card == nil {
+	return c.JSON(struct {
+		*models.Card
+		RemainingVotes *int `json:"remaining_votes,omitempty"`
+	}{card, result.RemainingVotes})
+}
+
+// GetCardVotes returns vote counts and the current user's vote on a card. Staff, and everyone
+// when PUBLIC_VOTERS is enabled, also get the paginated list of voters.
+func (h *Handler) GetCardVotes(c *fiber.Ctx) error {
+	cardID, _ := strconv.ParseInt(c.Params("id"), 10, 64)
+	staff := h.isStaff(c)
+
+	card, err := h.repo.GetCard(c.UserContext(), cardID, staff)
+	if err != nil {
+		return h.dbError(c, err, "Error loading votes")
+	}
+	if card == nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Card not found"})
 	}
-	card.UserVote = newValue
 
-	return c.JSON(card)
+	var userVote int
+	if user, ok := c.Locals("user").(*models.User); ok && user != nil {
+		if userVote, err = h.repo.GetUserVote(c.UserContext(), user.ID, cardID); err != nil {
+			return h.dbError(c, err, "Error loading votes")
+		}
+	}
+
+	result := fiber.Map{
+		"likes":     card.Likes,
+		"dislikes":  card.Dislikes,
+		"user_vote": userVote,
+	}
+
+	if !staff && !h.cfg.PublicVoters {
+		return c.JSON(result)
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+	offset := (page - 1) * limit
+
+	voters, total, err := h.repo.ListCardVotes(c.UserContext(), cardID, staff, limit, offset)
+	if err != nil {
+		return h.dbError(c, err, "Error loading votes")
+	}
+
+	result["voters"] = voters
+	result["total"] = total
+	result["has_more"] = offset+len(voters) < total
+	return c.JSON(result)
 }
 
-// GetComments returns comments for a card as JSON
+// GetComments returns a page of comments for a card as JSON.
+// Pages are selected with after=<id> or before=<id>; has_more refers to the same direction.
 func (h *Handler) GetComments(c *fiber.Ctx) error {
 	cardID, _ := strconv.ParseInt(c.Params("id"), 10, 64)
-	comments, err := h.repo.GetComments(cardID)
+
+	limit, _ := strconv.Atoi(c.Query("limit", strconv.Itoa(defaultCommentsLimit)))
+	if limit < 1 || limit > maxCommentsLimit {
+		limit = defaultCommentsLimit
+	}
+	page := repository.CommentPage{Limit: limit}
+	page.After, _ = strconv.ParseInt(c.Query("after"), 10, 64)
+	page.Before, _ = strconv.ParseInt(c.Query("before"), 10, 64)
+	if page.After > 0 && page.Before > 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Use either after or before"})
+	}
+
+	comments, total, hasMore, err := h.repo.ListComments(c.UserContext(), cardID, page, h.isStaff(c))
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Error loading comments"})
+		return h.dbError(c, err, "Error loading comments")
 	}
-	return c.JSON(comments)
+
+	var userID int64
+	if user, ok := c.Locals("user").(*models.User); ok && user != nil {
+		userID = user.ID
+	}
+	if err := h.attachCommentReactions(c.UserContext(), comments, userID); err != nil {
+		return h.dbError(c, err, "Error loading reactions")
+	}
+
+	return c.JSON(fiber.Map{
+		"comments": comments,
+		"total":    total,
+		"has_more": hasMore,
+	})
 }
 
 // APICreateComment creates a comment and returns JSON
@@ -205,8 +462,9 @@ func (h *Handler) APICreateComment(c *fiber.Ctx) error {
 	cardID, _ := strconv.ParseInt(c.Params("id"), 10, 64)
 
 	var input struct {
-		Content string   `json:"content"`
-		Images  []string `json:"images"`
+		Content  string   `json:"content"`
+		Images   []string `json:"images"`
+		Internal bool     `json:"internal"`
 	}
 
 	if err := c.BodyParser(&input); err != nil {
@@ -218,59 +476,42 @@ func (h *Handler) APICreateComment(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Comment cannot be empty"})
 	}
 
-	comment := &models.Comment{
-		CardID:    cardID,
-		UserID:    user.ID,
-		Content:   content,
-		Images:    input.Images,
-		CreatedAt: time.Now(),
-		Author:    user,
-	}
-
-	if err := h.repo.CreateComment(comment); err != nil {
-		log.Printf("Error creating comment: %v", err)
-		return c.Status(500).JSON(fiber.Map{"error": "Error creating comment"})
+	if input.Internal && !h.cfg.IsAdmin(user.ID) {
+		return c.Status(403).JSON(fiber.Map{"error": "Only staff can post internal comments"})
 	}
 
-	// Send notification to card author (if not commenting on own card)
-	go h.notifyNewComment(cardID, user, comment)
-
-	return c.Status(201).JSON(comment)
-}
-
-func (h *Handler) notifyNewComment(cardID int64, commenter *models.User, comment *models.Comment) {
-	card, err := h.repo.GetCard(cardID)
-	if err != nil || card == nil {
-		return
+	card, err := h.repo.GetCard(c.UserContext(), cardID, false)
+	if err != nil {
+		return h.dbError(c, err, "Error creating comment")
 	}
-
-	// Don't notify if user comments on their own card
-	if card.UserID == commenter.ID {
-		return
+	if card == nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Card not found"})
 	}
 
-	var link string
-	if h.cfg.AppURL != "" {
-		link = fmt.Sprintf("\n\n<a href=\"%s/c/%d\">Открыть карточку</a>", h.cfg.AppURL, card.ID)
+	comment := &models.Comment{
+		CardID:     cardID,
+		UserID:     user.ID,
+		Content:    content,
+		Images:     input.Images,
+		IsInternal: input.Internal,
+		CreatedAt:  time.Now(),
+		Author:     user,
 	}
 
-	commenterName := commenter.FirstName
-	if commenter.LastName != "" {
-		commenterName += " " + commenter.LastName
+	// Internal notes only mention staff
+	mentioned, err := h.mentionedUsers(c.UserContext(), content, user, input.Internal)
+	if err != nil {
+		return h.dbError(c, err, "Error creating comment")
 	}
+	notify := commentNotifications(card, user, input.Internal, mentioned)
 
-	// Truncate long comments
-	content := comment.Content
-	if len(content) > 200 {
-		content = content[:200] + "..."
+	if err := h.repo.CreateComment(c.UserContext(), comment, mentioned, notify); err != nil {
+		log.Printf("Error creating comment: %v", err)
+		return h.dbError(c, err, "Error creating comment")
 	}
+	h.wakeNotifier()
 
-	message := fmt.Sprintf("💬 <b>Новый комментарий к вашей карточке</b>\n\n\"%s\"\n\n<b>%s</b>: %s%s",
-		card.Title, commenterName, content, link)
-
-	if err := h.telegram.SendMessage(card.UserID, message); err != nil {
-		log.Printf("Failed to send comment notification to user %d: %v", card.UserID, err)
-	}
+	return c.Status(201).JSON(comment)
 }
 
 // APITelegramAuth handles Telegram auth for JSON API
@@ -293,8 +534,8 @@ func (h *Handler) APITelegramAuth(c *fiber.Ctx) error {
 		AuthDate:  time.Unix(data.AuthDate, 0),
 	}
 
-	if err := h.repo.UpsertUser(user); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Database error"})
+	if err := h.repo.UpsertUser(c.UserContext(), user); err != nil {
+		return h.dbError(c, err, "Database error")
 	}
 
 	c.Cookie(&fiber.Cookie{
@@ -398,8 +639,8 @@ func (h *Handler) APIDeleteCard(c *fiber.Ctx) error {
 	}
 
 	cardID, _ := strconv.ParseInt(c.Params("id"), 10, 64)
-	if err := h.repo.DeleteCard(cardID); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete card"})
+	if err := h.repo.DeleteCard(c.UserContext(), cardID); err != nil {
+		return h.dbError(c, err, "Failed to delete card")
 	}
 
 	return c.JSON(fiber.Map{"ok": true})
@@ -417,13 +658,53 @@ func (h *Handler) APIDeleteComment(c *fiber.Ctx) error {
 	}
 
 	commentID, _ := strconv.ParseInt(c.Params("id"), 10, 64)
-	if err := h.repo.DeleteComment(commentID); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete comment"})
+	if err := h.repo.DeleteComment(c.UserContext(), commentID); err != nil {
+		return h.dbError(c, err, "Failed to delete comment")
 	}
 
 	return c.JSON(fiber.Map{"ok": true})
 }
 
+// APISetCommentOfficial marks or unmarks a comment as the official response (admin only)
+func (h *Handler) APISetCommentOfficial(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login required"})
+	}
+
+	if !h.cfg.IsAdmin(user.ID) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin access required"})
+	}
+
+	commentID, _ := strconv.ParseInt(c.Params("id"), 10, 64)
+
+	var input struct {
+		Official bool `json:"official"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	comment, err := h.repo.GetComment(c.UserContext(), commentID)
+	if err != nil {
+		return h.dbError(c, err, "Failed to get comment")
+	}
+	if comment == nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Comment not found"})
+	}
+
+	if input.Official && comment.IsInternal {
+		return c.Status(400).JSON(fiber.Map{"error": "Internal comments cannot be official responses"})
+	}
+
+	if err := h.repo.SetCommentOfficial(c.UserContext(), commentID, input.Official); err != nil {
+		return h.dbError(c, err, "Failed to update comment")
+	}
+	comment.IsOfficial = input.Official
+
+	return c.JSON(comment)
+}
+
 // APIUpdateCardStatus updates card status (admin only)
 func (h *Handler) APIUpdateCardStatus(c *fiber.Ctx) error {
 	user, ok := c.Locals("user").(*models.User)
@@ -444,52 +725,230 @@ func (h *Handler) APIUpdateCardStatus(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
 	}
 
-	validStatuses := map[string]bool{"open": true, "closed": true, "fixed": true, "fix_coming": true}
-	if !validStatuses[input.Status] {
+	if !models.CardStatuses[input.Status] {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid status"})
 	}
 
-	if err := h.repo.UpdateCardStatus(cardID, input.Status); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to update status"})
+	card, err := h.repo.GetCard(c.UserContext(), cardID, true)
+	if err != nil {
+		return h.dbError(c, err, "Failed to get card")
+	}
+	if card == nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Card not found"})
 	}
 
-	card, err := h.repo.GetCard(cardID)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to get card"})
+	// The card author hears about every status change
+	var notify []*models.Notification
+	if card.UserID != 0 {
+		notify = append(notify, &models.Notification{
+			UserID:     card.UserID,
+			Event:      eventStatus,
+			CardID:     card.ID,
+			ActorID:    user.ID,
+			CardStatus: input.Status,
+		})
 	}
+	if err := h.repo.UpdateCardStatus(c.UserContext(), cardID, input.Status, notify); err != nil {
+		return h.dbError(c, err, "Failed to update status")
+	}
+	h.wakeNotifier()
 
-	// Send notification to card author
-	go h.notifyStatusChange(card, input.Status)
+	card, err = h.repo.GetCard(c.UserContext(), cardID, true)
+	if err != nil {
+		return h.dbError(c, err, "Failed to get card")
+	}
 
 	return c.JSON(card)
 }
 
-func (h *Handler) notifyStatusChange(card *models.Card, newStatus string) {
-	if card == nil || card.UserID == 0 {
-		return
+// GetStaffActivity returns comment counts per staff member, internal notes included (admin only)
+func (h *Handler) GetStaffActivity(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login required"})
+	}
+
+	if !h.cfg.IsAdmin(user.ID) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin access required"})
+	}
+
+	days, _ := strconv.Atoi(c.Query("days", "30"))
+	if days < 1 || days > 365 {
+		days = 30
+	}
+	since := time.Now().AddDate(0, 0, -days)
+
+	activity, err := h.repo.GetStaffActivity(c.UserContext(), h.cfg.AdminIDs, since)
+	if err != nil {
+		return h.dbError(c, err, "Failed to load activity")
+	}
+
+	return c.JSON(fiber.Map{
+		"since":    since,
+		"activity": activity,
+	})
+}
+
+func (h *Handler) voteBudget() repository.VoteBudget {
+	if h.cfg.VoteBudget <= 0 {
+		return repository.VoteBudget{}
+	}
+	since, _ := h.budgetPeriod(time.Now())
+	return repository.VoteBudget{
+		Limit: h.cfg.VoteBudget,
+		Since: since,
+	}
+}
+
+// budgetPeriod returns the start and end of the vote budget period containing now
+func (h *Handler) budgetPeriod(now time.Time) (time.Time, time.Time) {
+	loc, err := time.LoadLocation(h.cfg.VoteBudgetTimezone)
+	if err != nil {
+		loc = time.UTC
 	}
+	return calendarPeriod(now.In(loc), h.cfg.VoteBudgetPeriod)
+}
 
-	statusLabels := map[string]string{
-		"open":       "Open",
-		"closed":     "Closed",
-		"fixed":      "Fixed",
-		"fix_coming": "Fix Coming",
+// calendarPeriod returns the start and end of the calendar day, week (from Monday) or
+// month containing t, in t's location. Unknown periods are months.
+func calendarPeriod(t time.Time, period string) (time.Time, time.Time) {
+	year, month, day := t.Date()
+	switch period {
+	case "day":
+		start := time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 0, 1)
+	case "week":
+		// Weekday counts from Sunday
+		start := time.Date(year, month, day-(int(t.Weekday())+6)%7, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 0, 7)
+	default:
+		start := time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 1, 0)
 	}
+}
 
-	statusLabel := statusLabels[newStatus]
-	if statusLabel == "" {
-		statusLabel = newStatus
+// GetMyVoteBudget returns the current user's suggestion vote budget for this period
+func (h *Handler) GetMyVoteBudget(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login required"})
 	}
 
-	var link string
-	if h.cfg.AppURL != "" {
-		link = fmt.Sprintf("\n\n<a href=\"%s/c/%d\">Открыть карточку</a>", h.cfg.AppURL, card.ID)
+	budget := h.voteBudget()
+	if budget.Limit == 0 {
+		return c.JSON(fiber.Map{"enabled": false})
 	}
+	_, resetsAt := h.budgetPeriod(budget.Since)
 
-	message := fmt.Sprintf("📋 <b>Статус вашей карточки изменен</b>\n\n\"%s\"\n\nНовый статус: <b>%s</b>%s",
-		card.Title, statusLabel, link)
+	used, err := h.repo.GetVoteBudgetUsed(c.UserContext(), user.ID, budget.Since)
+	if err != nil {
+		return h.dbError(c, err, "Error loading vote budget")
+	}
 
-	if err := h.telegram.SendMessage(card.UserID, message); err != nil {
-		log.Printf("Failed to send status notification to user %d: %v", card.UserID, err)
+	remaining := budget.Limit - used
+	if remaining < 0 {
+		remaining = 0
 	}
+
+	return c.JSON(fiber.Map{
+		"enabled":      true,
+		"limit":        budget.Limit,
+		"used":         used,
+		"remaining":    remaining,
+		"period_start": budget.Since,
+		"period_end":   resetsAt,
+		"resets_at":    resetsAt,
+	})
+}
+
+func (h *Handler) voteRules() repository.VoteRules {
+	return repository.VoteRules{
+		MinAccountAge:   h.cfg.VoteMinAccountAge,
+		NewAccountAge:   h.cfg.VoteNewAccountAge,
+		UserBurstLimit:  h.cfg.VoteUserBurstLimit,
+		UserBurstWindow: h.cfg.VoteUserBurstWindow,
+		CardBurstLimit:  h.cfg.VoteCardBurstLimit,
+		CardBurstWindow: h.cfg.VoteCardBurstWindow,
+	}
+}
+
+// GetReviewVotes lists flagged (or, with status=voided, voided) votes for review (admin only)
+func (h *Handler) GetReviewVotes(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login required"})
+	}
+
+	if !h.cfg.IsAdmin(user.ID) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin access required"})
+	}
+
+	status := c.Query("status", repository.VoteFlagged)
+	if status != repository.VoteFlagged && status != repository.VoteVoided {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid status"})
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+	offset := (page - 1) * limit
+
+	votes, total, err := h.repo.ListReviewVotes(c.UserContext(), status, limit, offset)
+	if err != nil {
+		return h.dbError(c, err, "Failed to load votes")
+	}
+
+	return c.JSON(fiber.Map{
+		"votes":    votes,
+		"total":    total,
+		"has_more": offset+len(votes) < total,
+	})
+}
+
+// APIReviewVote approves (status=valid) or voids (status=voided) a vote (admin only)
+func (h *Handler) APIReviewVote(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login required"})
+	}
+
+	if !h.cfg.IsAdmin(user.ID) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin access required"})
+	}
+
+	cardID, _ := strconv.ParseInt(c.Params("id"), 10, 64)
+	voterID, _ := strconv.ParseInt(c.Params("userId"), 10, 64)
+
+	var input struct {
+		Status string `json:"status"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if input.Status != repository.VoteValid && input.Status != repository.VoteVoided {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid status"})
+	}
+
+	found, err := h.repo.SetVoteStatus(c.UserContext(), voterID, cardID, input.Status)
+	if err != nil {
+		return h.dbError(c, err, "Failed to update vote")
+	}
+	if !found {
+		return c.Status(404).JSON(fiber.Map{"error": "Vote not found"})
+	}
+
+	card, err := h.repo.GetCard(c.UserContext(), cardID, true)
+	if err != nil {
+		return h.dbError(c, err, "Failed to get card")
+	}
+	if card == nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Card not found"})
+	}
+
+	return c.JSON(card)
 }
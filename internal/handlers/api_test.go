@@ -0,0 +1,554 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"bugtracker/internal/models"
+)
+
+type cardsResponse struct {
+	Cards      []*models.Card     `json:"cards"`
+	Total      *int               `json:"total"`
+	HasMore    bool               `json:"has_more"`
+	NextCursor string             `json:"next_cursor"`
+	Facets     *models.CardFacets `json:"facets"`
+}
+
+func (ts *testServer) listCards(t *testing.T, userID int64, params url.Values) cardsResponse {
+	t.Helper()
+	r := ts.request(t, "GET", "/api/cards?"+params.Encode(), userID, nil)
+	expect(t, r, 200)
+	var body cardsResponse
+	r.decode(t, &body)
+	return body
+}
+
+func cardIDs(cards []*models.Card) []int64 {
+	ids := make([]int64, len(cards))
+	for i, c := range cards {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+func TestCreateCard(t *testing.T) {
+	ts := newTestServer(t)
+
+	expect(t, ts.request(t, "POST", "/api/cards", anonymous, fiber.Map{"title": "Crash"}), 401)
+	expect(t, ts.request(t, "POST", "/api/cards", aliceID, fiber.Map{"description": "no title"}), 400)
+
+	r := ts.request(t, "POST", "/api/cards", aliceID, fiber.Map{"title": "Crash on start", "description": "Every time"})
+	expect(t, r, 201)
+	var card models.Card
+	r.decode(t, &card)
+	if card.ID == 0 || card.Type != "issue" || card.Status != "open" || card.UserID != aliceID {
+		t.Errorf("card = %+v", card)
+	}
+}
+
+func TestGetCard(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.createCard(t, aliceID, "issue", "Crash on start", "")
+	ts.createComment(t, bobID, id, "Same here", false)
+	ts.createComment(t, adminID, id, "Known issue", true)
+
+	expect(t, ts.request(t, "GET", "/api/cards/999", anonymous, nil), 404)
+
+	for _, tt := range []struct {
+		userID   int64
+		comments int
+	}{{anonymous, 1}, {bobID, 1}, {adminID, 2}} {
+		r := ts.request(t, "GET", fmt.Sprintf("/api/cards/%d", id), tt.userID, nil)
+		expect(t, r, 200)
+		var body struct {
+			Card          models.Card       `json:"card"`
+			Comments      []*models.Comment `json:"comments"`
+			CommentsTotal int               `json:"comments_total"`
+		}
+		r.decode(t, &body)
+		if body.Card.CommentCount != tt.comments || len(body.Comments) != tt.comments || body.CommentsTotal != tt.comments {
+			t.Errorf("user %d: comment_count %d, %d comments, total %d; want %d",
+				tt.userID, body.Card.CommentCount, len(body.Comments), body.CommentsTotal, tt.comments)
+		}
+		if body.Card.Author == nil || body.Card.Author.Username != "alice" {
+			t.Errorf("author = %+v", body.Card.Author)
+		}
+	}
+}
+
+func TestGetCardsFiltersAndSorts(t *testing.T) {
+	ts := newTestServer(t)
+	crash := ts.createCard(t, aliceID, "issue", "Crash on start", "The app crashes")
+	dark := ts.createCard(t, bobID, "suggestion", "Dark theme", "Please add a dark theme")
+	slow := ts.createCard(t, aliceID, "issue", "Slow search", "")
+	ts.store.SetCardTags(crash, "ios", "startup")
+	ts.store.SetCardTags(slow, "ios")
+	ts.request(t, "POST", fmt.Sprintf("/api/cards/%d/vote", dark), aliceID, fiber.Map{"value": 1})
+	ts.request(t, "POST", fmt.Sprintf("/api/cards/%d/vote", slow), bobID, fiber.Map{"value": -1})
+
+	tests := []struct {
+		name   string
+		params url.Values
+		want   []int64
+	}{
+		{"rating", url.Values{}, []int64{dark, crash, slow}},
+		{"time", url.Values{"sort": {"time"}}, []int64{slow, dark, crash}},
+		{"type", url.Values{"type": {"issue"}}, []int64{crash, slow}},
+		{"query filters", url.Values{"query": {"type:suggestion"}}, []int64{dark}},
+		{"author", url.Values{"query": {"author:alice"}, "sort": {"time"}}, []int64{slow, crash}},
+		{"votes", url.Values{"query": {"votes:<0"}}, []int64{slow}},
+		{"tags", url.Values{"tags": {"ios,startup"}}, []int64{crash}},
+		{"full text", url.Values{"query": {"crash"}}, []int64{crash}},
+		{"contradicting", url.Values{"type": {"issue"}, "query": {"type:suggestion"}}, []int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := ts.listCards(t, anonymous, tt.params)
+			if got := cardIDs(body.Cards); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("cards = %v, want %v", got, tt.want)
+			}
+			if body.Total == nil || *body.Total != len(tt.want) {
+				t.Errorf("total = %v, want %d", body.Total, len(tt.want))
+			}
+		})
+	}
+
+	body := ts.listCards(t, anonymous, url.Values{"query": {"crash"}})
+	if c := body.Cards[0]; c.TitleHighlight != "<mark>Crash</mark> on start" {
+		t.Errorf("title_highlight = %q", c.TitleHighlight)
+	}
+
+	r := ts.request(t, "GET", "/api/cards?query="+url.QueryEscape("votes:>x"), anonymous, nil)
+	expect(t, r, 400)
+	expect(t, ts.request(t, "GET", "/api/cards?sort=random", anonymous, nil), 400)
+}
+
+func TestGetCardsCursor(t *testing.T) {
+	ts := newTestServer(t)
+	var ids []int64
+	for i := 0; i < 5; i++ {
+		ids = append(ids, ts.createCard(t, aliceID, "issue", fmt.Sprintf("Card %d", i), ""))
+	}
+
+	var seen []int64
+	params := url.Values{"sort": {"time"}, "limit": {"2"}}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("cursor pagination does not end")
+		}
+		body := ts.listCards(t, anonymous, params)
+		seen = append(seen, cardIDs(body.Cards)...)
+		if pages > 0 && body.Total != nil {
+			t.Errorf("total = %d on a cursor page, want none", *body.Total)
+		}
+		if !body.HasMore {
+			break
+		}
+		params.Set("cursor", body.NextCursor)
+	}
+	want := []int64{ids[4], ids[3], ids[2], ids[1], ids[0]}
+	if fmt.Sprint(seen) != fmt.Sprint(want) {
+		t.Errorf("pages = %v, want %v", seen, want)
+	}
+
+	first := ts.listCards(t, anonymous, url.Values{"sort": {"time"}, "limit": {"2"}})
+	r := ts.request(t, "GET", "/api/cards?sort=rate&cursor="+first.NextCursor, anonymous, nil)
+	expect(t, r, 400)
+	expect(t, ts.request(t, "GET", "/api/cards?cursor=garbage", anonymous, nil), 400)
+}
+
+func TestGetCardsFacets(t *testing.T) {
+	ts := newTestServer(t)
+	crash := ts.createCard(t, aliceID, "issue", "Crash", "")
+	ts.createCard(t, aliceID, "issue", "Slow", "")
+	dark := ts.createCard(t, bobID, "suggestion", "Dark theme", "")
+	ts.store.SetCardTags(crash, "ios")
+	ts.store.SetCardTags(dark, "ios")
+
+	body := ts.listCards(t, anonymous, url.Values{"type": {"issue"}, "facets": {"true"}})
+	if body.Facets == nil {
+		t.Fatal("no facets")
+	}
+	if got := fmt.Sprint(body.Facets.Types); got != "[{issue 2} {suggestion 1}]" {
+		t.Errorf("type facet = %s, want counts ignoring the type filter", got)
+	}
+	if got := fmt.Sprint(body.Facets.Tags); got != "[{ios 1}]" {
+		t.Errorf("tag facet = %s", got)
+	}
+}
+
+func TestGetSimilarCards(t *testing.T) {
+	ts := newTestServer(t)
+	crash := ts.createCard(t, aliceID, "issue", "App crashes on start", "")
+	closed := ts.createCard(t, aliceID, "issue", "App crashes on exit", "")
+	ts.createCard(t, aliceID, "suggestion", "Dark theme", "")
+	expect(t, ts.request(t, "PATCH", fmt.Sprintf("/api/cards/%d/status", closed), adminID, fiber.Map{"status": "closed"}), 200)
+
+	r := ts.request(t, "GET", "/api/cards/similar?title="+url.QueryEscape("app crashes at start"), anonymous, nil)
+	expect(t, r, 200)
+	var body struct {
+		Cards []*models.SimilarCard `json:"cards"`
+	}
+	r.decode(t, &body)
+	if len(body.Cards) != 1 || body.Cards[0].ID != crash {
+		t.Errorf("similar = %+v, want only the open card %d", body.Cards, crash)
+	}
+
+	r = ts.request(t, "GET", "/api/cards/similar?title=ap", anonymous, nil)
+	expect(t, r, 200)
+	r.decode(t, &body)
+	if len(body.Cards) != 0 {
+		t.Errorf("short title gave %d cards", len(body.Cards))
+	}
+}
+
+func TestUpdateCardStatus(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.createCard(t, aliceID, "issue", "Crash", "")
+	path := fmt.Sprintf("/api/cards/%d/status", id)
+
+	expect(t, ts.request(t, "PATCH", path, anonymous, fiber.Map{"status": "fixed"}), 401)
+	expect(t, ts.request(t, "PATCH", path, aliceID, fiber.Map{"status": "fixed"}), 403)
+	expect(t, ts.request(t, "PATCH", path, adminID, fiber.Map{"status": "done"}), 400)
+
+	r := ts.request(t, "PATCH", path, adminID, fiber.Map{"status": "fixed"})
+	expect(t, r, 200)
+	var card models.Card
+	r.decode(t, &card)
+	if card.Status != "fixed" {
+		t.Errorf("status = %q", card.Status)
+	}
+}
+
+func TestDeleteCardCascades(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.createCard(t, aliceID, "issue", "Crash", "")
+	commentID := ts.createComment(t, bobID, id, "Same", false)
+	ts.request(t, "POST", fmt.Sprintf("/api/cards/%d/vote", id), bobID, fiber.Map{"value": 1})
+	path := fmt.Sprintf("/api/cards/%d", id)
+
+	expect(t, ts.request(t, "DELETE", path, anonymous, nil), 401)
+	expect(t, ts.request(t, "DELETE", path, aliceID, nil), 403)
+	expect(t, ts.request(t, "DELETE", path, adminID, nil), 200)
+
+	expect(t, ts.request(t, "GET", path, anonymous, nil), 404)
+	expect(t, ts.request(t, "POST", fmt.Sprintf("/api/comments/%d/reactions", commentID), bobID, fiber.Map{"emoji": "👍"}), 404)
+	if n, _ := ts.store.GetUserVote(t.Context(), bobID, id); n != 0 {
+		t.Errorf("vote survived the card: %d", n)
+	}
+}
+
+func TestVote(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.createCard(t, aliceID, "issue", "Crash", "")
+	path := fmt.Sprintf("/api/cards/%d/vote", id)
+
+	expect(t, ts.request(t, "POST", path, anonymous, fiber.Map{"value": 1}), 401)
+	expect(t, ts.request(t, "POST", path, bobID, fiber.Map{"value": 2}), 400)
+	expect(t, ts.request(t, "POST", "/api/cards/999/vote", bobID, fiber.Map{"value": 1}), 404)
+
+	steps := []struct {
+		userID                 int64
+		value                  int
+		userVote, likes, dislk int
+	}{
+		{bobID, 1, 1, 1, 0},
+		{adminID, -1, -1, 1, 1},
+		{bobID, 1, 0, 0, 1},   // the same vote again removes it
+		{bobID, -1, -1, 0, 2}, // then a dislike
+		{bobID, 1, 1, 1, 1},   // switching sides
+	}
+	for i, s := range steps {
+		r := ts.request(t, "POST", path, s.userID, fiber.Map{"value": s.value})
+		expect(t, r, 200)
+		var card models.Card
+		r.decode(t, &card)
+		if card.UserVote != s.userVote || card.Likes != s.likes || card.Dislikes != s.dislk || card.Rating != s.likes-s.dislk {
+			t.Errorf("step %d: vote %d, likes %d, dislikes %d, rating %d; want %d, %d, %d",
+				i, card.UserVote, card.Likes, card.Dislikes, card.Rating, s.userVote, s.likes, s.dislk)
+		}
+	}
+}
+
+func TestVoteBudget(t *testing.T) {
+	ts := newTestServer(t)
+	ts.cfg.VoteBudget = 1
+	first := ts.createCard(t, aliceID, "suggestion", "Dark theme", "")
+	second := ts.createCard(t, aliceID, "suggestion", "Widgets", "")
+	issue := ts.createCard(t, aliceID, "issue", "Crash", "")
+
+	r := ts.request(t, "POST", fmt.Sprintf("/api/cards/%d/vote", first), bobID, fiber.Map{"value": 1})
+	expect(t, r, 200)
+	var body struct {
+		RemainingVotes *int `json:"remaining_votes"`
+	}
+	r.decode(t, &body)
+	if body.RemainingVotes == nil || *body.RemainingVotes != 0 {
+		t.Errorf("remaining_votes = %v, want 0", body.RemainingVotes)
+	}
+
+	expect(t, ts.request(t, "POST", fmt.Sprintf("/api/cards/%d/vote", second), bobID, fiber.Map{"value": 1}), 409)
+	expect(t, ts.request(t, "POST", fmt.Sprintf("/api/cards/%d/vote", second), bobID, fiber.Map{"value": -1}), 200)
+	expect(t, ts.request(t, "POST", fmt.Sprintf("/api/cards/%d/vote", issue), bobID, fiber.Map{"value": 1}), 200)
+
+	// Closing a suggestion gives its votes back
+	ts.request(t, "PATCH", fmt.Sprintf("/api/cards/%d/status", first), adminID, fiber.Map{"status": "closed"})
+	r = ts.request(t, "GET", "/api/me/vote-budget", bobID, nil)
+	expect(t, r, 200)
+	var budget struct {
+		Enabled     bool      `json:"enabled"`
+		Used        int       `json:"used"`
+		Remaining   int       `json:"remaining"`
+		PeriodStart time.Time `json:"period_start"`
+		ResetsAt    time.Time `json:"resets_at"`
+	}
+	r.decode(t, &budget)
+	if !budget.Enabled || budget.Used != 0 || budget.Remaining != 1 {
+		t.Errorf("budget = %+v", budget)
+	}
+	if budget.PeriodStart.UTC().Day() != 1 || !budget.ResetsAt.Equal(budget.PeriodStart.AddDate(0, 1, 0)) {
+		t.Errorf("budget period = %s to %s, want a calendar month", budget.PeriodStart, budget.ResetsAt)
+	}
+
+	expect(t, ts.request(t, "GET", "/api/me/vote-budget", anonymous, nil), 401)
+	ts.cfg.VoteBudget = 0
+	r = ts.request(t, "GET", "/api/me/vote-budget", bobID, nil)
+	r.decode(t, &budget)
+	if budget.Enabled {
+		t.Error("budget enabled with VOTE_BUDGET=0")
+	}
+}
+
+func TestCalendarPeriod(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	// Wednesday
+	now := time.Date(2026, 10, 21, 1, 30, 0, 0, moscow)
+	for period, want := range map[string][2]time.Time{
+		"day":   {time.Date(2026, 10, 21, 0, 0, 0, 0, moscow), time.Date(2026, 10, 22, 0, 0, 0, 0, moscow)},
+		"week":  {time.Date(2026, 10, 19, 0, 0, 0, 0, moscow), time.Date(2026, 10, 26, 0, 0, 0, 0, moscow)},
+		"month": {time.Date(2026, 10, 1, 0, 0, 0, 0, moscow), time.Date(2026, 11, 1, 0, 0, 0, 0, moscow)},
+		"":      {time.Date(2026, 10, 1, 0, 0, 0, 0, moscow), time.Date(2026, 11, 1, 0, 0, 0, 0, moscow)},
+	} {
+		start, end := calendarPeriod(now, period)
+		if !start.Equal(want[0]) || !end.Equal(want[1]) {
+			t.Errorf("calendarPeriod(%q) = %s to %s, want %s to %s", period, start, end, want[0], want[1])
+		}
+	}
+
+	// A week started on Sunday belongs to the week from the Monday before
+	sunday := time.Date(2026, 10, 25, 23, 0, 0, 0, moscow)
+	if start, _ := calendarPeriod(sunday, "week"); !start.Equal(time.Date(2026, 10, 19, 0, 0, 0, 0, moscow)) {
+		t.Errorf("week of a Sunday starts %s", start)
+	}
+}
+
+func TestVoteReview(t *testing.T) {
+	ts := newTestServer(t)
+	ts.cfg.VoteMinAccountAge = time.Hour
+	id := ts.createCard(t, aliceID, "issue", "Crash", "")
+	ts.store.SetUserCreatedAt(bobID, time.Now())
+
+	// A vote from a new account is flagged and doesn't count
+	r := ts.request(t, "POST", fmt.Sprintf("/api/cards/%d/vote", id), bobID, fiber.Map{"value": 1})
+	expect(t, r, 200)
+	var card models.Card
+	r.decode(t, &card)
+	if card.Likes != 0 || card.UserVote != 1 {
+		t.Errorf("likes %d, user vote %d; want a flagged vote", card.Likes, card.UserVote)
+	}
+
+	expect(t, ts.request(t, "GET", "/api/admin/votes", anonymous, nil), 401)
+	expect(t, ts.request(t, "GET", "/api/admin/votes", bobID, nil), 403)
+	r = ts.request(t, "GET", "/api/admin/votes", adminID, nil)
+	expect(t, r, 200)
+	var review struct {
+		Votes []*models.Vote `json:"votes"`
+		Total int            `json:"total"`
+	}
+	r.decode(t, &review)
+	if review.Total != 1 || review.Votes[0].FlagReason != "new_account" || review.Votes[0].CardTitle != "Crash" {
+		t.Fatalf("review = %+v", review)
+	}
+
+	path := fmt.Sprintf("/api/admin/cards/%d/votes/%d", id, bobID)
+	expect(t, ts.request(t, "PATCH", path, bobID, fiber.Map{"status": "valid"}), 403)
+	expect(t, ts.request(t, "PATCH", path, adminID, fiber.Map{"status": "flagged"}), 400)
+	expect(t, ts.request(t, "PATCH", fmt.Sprintf("/api/admin/cards/%d/votes/%d", id, aliceID), adminID, fiber.Map{"status": "valid"}), 404)
+
+	r = ts.request(t, "PATCH", path, adminID, fiber.Map{"status": "valid"})
+	expect(t, r, 200)
+	r.decode(t, &card)
+	if card.Likes != 1 {
+		t.Errorf("likes = %d after approving", card.Likes)
+	}
+
+	// Voided votes stay voided when changed
+	expect(t, ts.request(t, "PATCH", path, adminID, fiber.Map{"status": "voided"}), 200)
+	ts.store.SetUserCreatedAt(bobID, time.Now().AddDate(0, -1, 0))
+	r = ts.request(t, "POST", fmt.Sprintf("/api/cards/%d/vote", id), bobID, fiber.Map{"value": -1})
+	r.decode(t, &card)
+	if card.Likes != 0 || card.Dislikes != 0 {
+		t.Errorf("likes %d, dislikes %d; a voided vote counted", card.Likes, card.Dislikes)
+	}
+}
+
+func TestGetCardVotes(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.createCard(t, aliceID, "issue", "Crash", "")
+	ts.request(t, "POST", fmt.Sprintf("/api/cards/%d/vote", id), bobID, fiber.Map{"value": 1})
+	path := fmt.Sprintf("/api/cards/%d/votes", id)
+
+	type votesResponse struct {
+		Likes    int            `json:"likes"`
+		UserVote int            `json:"user_vote"`
+		Voters   []*models.Vote `json:"voters"`
+	}
+	var body votesResponse
+
+	r := ts.request(t, "GET", path, bobID, nil)
+	expect(t, r, 200)
+	r.decode(t, &body)
+	if body.Likes != 1 || body.UserVote != 1 || body.Voters != nil {
+		t.Errorf("votes for a voter = %+v, want no voter list", body)
+	}
+
+	body = votesResponse{}
+	r = ts.request(t, "GET", path, adminID, nil)
+	r.decode(t, &body)
+	if len(body.Voters) != 1 || body.Voters[0].UserID != bobID || body.Voters[0].Status != "valid" {
+		t.Errorf("voters for staff = %+v", body.Voters)
+	}
+
+	ts.cfg.PublicVoters = true
+	body = votesResponse{}
+	r = ts.request(t, "GET", path, anonymous, nil)
+	r.decode(t, &body)
+	if len(body.Voters) != 1 || body.Voters[0].Status != "" {
+		t.Errorf("public voters = %+v, want no review status", body.Voters)
+	}
+
+	expect(t, ts.request(t, "GET", "/api/cards/999/votes", anonymous, nil), 404)
+}
+
+func TestComments(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.createCard(t, aliceID, "issue", "Crash", "")
+	path := fmt.Sprintf("/api/cards/%d/comments", id)
+
+	expect(t, ts.request(t, "POST", path, anonymous, fiber.Map{"content": "Hi"}), 401)
+	expect(t, ts.request(t, "POST", path, bobID, fiber.Map{"content": "  "}), 400)
+	expect(t, ts.request(t, "POST", path, bobID, fiber.Map{"content": "Note", "internal": true}), 403)
+
+	var ids []int64
+	for i := 0; i < 5; i++ {
+		ids = append(ids, ts.createComment(t, bobID, id, fmt.Sprintf("Comment %d", i), false))
+	}
+	ts.createComment(t, adminID, id, "Internal note", true)
+
+	type page struct {
+		Comments []*models.Comment `json:"comments"`
+		Total    int               `json:"total"`
+		HasMore  bool              `json:"has_more"`
+	}
+	commentIDs := func(p page) []int64 {
+		out := make([]int64, len(p.Comments))
+		for i, c := range p.Comments {
+			out[i] = c.ID
+		}
+		return out
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		userID  int64
+		want    []int64
+		total   int
+		hasMore bool
+	}{
+		{"first page", "?limit=2", anonymous, ids[:2], 5, true},
+		{"after", fmt.Sprintf("?limit=2&after=%d", ids[1]), anonymous, ids[2:4], 5, true},
+		{"before", fmt.Sprintf("?limit=2&before=%d", ids[4]), anonymous, ids[2:4], 5, true},
+		{"last page", fmt.Sprintf("?limit=2&after=%d", ids[3]), anonymous, ids[4:], 5, false},
+		{"staff see internal notes", "", adminID, append(append([]int64{}, ids...), ids[4]+1), 6, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := ts.request(t, "GET", path+tt.query, tt.userID, nil)
+			expect(t, r, 200)
+			var p page
+			r.decode(t, &p)
+			if fmt.Sprint(commentIDs(p)) != fmt.Sprint(tt.want) || p.Total != tt.total || p.HasMore != tt.hasMore {
+				t.Errorf("got %v total %d more %v; want %v total %d more %v",
+					commentIDs(p), p.Total, p.HasMore, tt.want, tt.total, tt.hasMore)
+			}
+		})
+	}
+
+	expect(t, ts.request(t, "GET", path+"?after=1&before=3", anonymous, nil), 400)
+}
+
+func TestDeleteComment(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.createCard(t, aliceID, "issue", "Crash", "")
+	commentID := ts.createComment(t, bobID, id, "Spam", false)
+	path := fmt.Sprintf("/api/comments/%d", commentID)
+
+	expect(t, ts.request(t, "DELETE", path, anonymous, nil), 401)
+	expect(t, ts.request(t, "DELETE", path, bobID, nil), 403)
+	expect(t, ts.request(t, "DELETE", path, adminID, nil), 200)
+
+	card, _ := ts.store.GetCard(t.Context(), id, true)
+	if card.CommentCount != 0 {
+		t.Errorf("comment_count = %d after deleting the only comment", card.CommentCount)
+	}
+}
+
+func TestSetCommentOfficial(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.createCard(t, aliceID, "issue", "Crash", "")
+	reply := ts.createComment(t, adminID, id, "Fixed in 1.2", false)
+	note := ts.createComment(t, adminID, id, "Internal", true)
+	path := fmt.Sprintf("/api/comments/%d/official", reply)
+
+	expect(t, ts.request(t, "PATCH", path, anonymous, fiber.Map{"official": true}), 401)
+	expect(t, ts.request(t, "PATCH", path, aliceID, fiber.Map{"official": true}), 403)
+	expect(t, ts.request(t, "PATCH", "/api/comments/999/official", adminID, fiber.Map{"official": true}), 404)
+	expect(t, ts.request(t, "PATCH", fmt.Sprintf("/api/comments/%d/official", note), adminID, fiber.Map{"official": true}), 400)
+	expect(t, ts.request(t, "PATCH", path, adminID, fiber.Map{"official": true}), 200)
+
+	r := ts.request(t, "GET", fmt.Sprintf("/api/cards/%d", id), anonymous, nil)
+	var body struct {
+		Card     models.Card       `json:"card"`
+		Official []*models.Comment `json:"official_responses"`
+	}
+	r.decode(t, &body)
+	if !body.Card.HasOfficialResponse || len(body.Official) != 1 || body.Official[0].ID != reply {
+		t.Errorf("card = %+v, official = %+v", body.Card, body.Official)
+	}
+}
+
+func TestStaffActivity(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.createCard(t, aliceID, "issue", "Crash", "")
+	ts.createComment(t, adminID, id, "Looking", false)
+	ts.createComment(t, adminID, id, "Internal", true)
+
+	expect(t, ts.request(t, "GET", "/api/admin/activity", anonymous, nil), 401)
+	expect(t, ts.request(t, "GET", "/api/admin/activity", aliceID, nil), 403)
+
+	r := ts.request(t, "GET", "/api/admin/activity?days=7", adminID, nil)
+	expect(t, r, 200)
+	var body struct {
+		Activity []*models.StaffActivity `json:"activity"`
+	}
+	r.decode(t, &body)
+	if len(body.Activity) != 1 || body.Activity[0].Comments != 2 || body.Activity[0].InternalComments != 1 {
+		t.Errorf("activity = %+v", body.Activity)
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Conditional requests for the endpoints the frontend polls. The ETag is derived from
+// a cheap version query of the store (CardsVersion, CardVersion) rather than from the
+// response body, so an unchanged card or list is answered with 304 before it is loaded.
+
+// etag returns a weak entity tag for the response to this URL built from data at version.
+// Responses differ per viewer (the user's own votes and reactions, internal comment
+// counts for staff), so the viewer is part of the tag.
+func (h *Handler) etag(c *fiber.Ctx, version string, userID int64) string {
+	sum := sha256.Sum256([]byte(c.OriginalURL() + "\n" + version + "\n" +
+		strconv.FormatInt(userID, 10) + "\n" + strconv.FormatBool(h.isStaff(c))))
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// setCacheHeaders sets the ETag and lets clients keep the response but revalidate it on
+// every use. Only anonymous responses may be kept by shared caches; they vary by the
+// session cookie so a logged-in user never gets them.
+func setCacheHeaders(c *fiber.Ctx, tag string, userID int64) {
+	c.Set(fiber.HeaderETag, tag)
+	if userID == 0 {
+		c.Set(fiber.HeaderCacheControl, "public, no-cache")
+	} else {
+		c.Set(fiber.HeaderCacheControl, "private, no-cache")
+	}
+	c.Vary(fiber.HeaderCookie)
+}
+
+// notModified reports whether the request's If-None-Match matches tag, using the weak
+// comparison, and if so sets the cache headers for a 304 response
+func notModified(c *fiber.Ctx, tag string, userID int64) bool {
+	header := c.Get(fiber.HeaderIfNoneMatch)
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(tag, "W/") {
+			setCacheHeaders(c, tag, userID)
+			return true
+		}
+	}
+	return false
+}
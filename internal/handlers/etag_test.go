@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"bugtracker/internal/models"
+	"bugtracker/internal/repository"
+)
+
+// conditionalGet requests path with If-None-Match set to tag
+func (ts *testServer) conditionalGet(t *testing.T, path string, userID int64, tag string) response {
+	t.Helper()
+	req := httptest.NewRequest("GET", path, nil)
+	req.Header.Set("If-None-Match", tag)
+	return ts.send(t, req, userID)
+}
+
+func TestCardsETag(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.createCard(t, aliceID, "issue", "Crash", "")
+	const path = "/api/cards?sort=time"
+
+	r := ts.request(t, "GET", path, aliceID, nil)
+	expect(t, r, 200)
+	tag := r.header.Get("ETag")
+	if len(tag) < 4 || tag[:3] != `W/"` {
+		t.Fatalf("ETag = %q, want a weak tag", tag)
+	}
+	if cc := r.header.Get("Cache-Control"); cc != "private, no-cache" {
+		t.Errorf("Cache-Control = %q", cc)
+	}
+	if vary := r.header.Get("Vary"); vary != "Cookie" {
+		t.Errorf("Vary = %q", vary)
+	}
+
+	r = ts.conditionalGet(t, path, aliceID, tag)
+	expect(t, r, 304)
+	if len(r.body) != 0 || r.header.Get("ETag") != tag {
+		t.Errorf("304 body %q, ETag %q", r.body, r.header.Get("ETag"))
+	}
+	// The strong form of the tag, and lists of tags, match too
+	expect(t, ts.conditionalGet(t, path, aliceID, `"x", `+tag[2:]), 304)
+
+	// Tags are per viewer, since responses carry the user's own votes
+	expect(t, ts.conditionalGet(t, path, bobID, tag), 200)
+	r = ts.conditionalGet(t, path, anonymous, tag)
+	expect(t, r, 200)
+	if cc := r.header.Get("Cache-Control"); cc != "public, no-cache" {
+		t.Errorf("anonymous Cache-Control = %q", cc)
+	}
+
+	changes := []struct {
+		name   string
+		method string
+		path   string
+		userID int64
+		body   interface{}
+	}{
+		{"vote", "POST", fmt.Sprintf("/api/cards/%d/vote", id), bobID, fiber.Map{"value": 1}},
+		{"reaction", "POST", fmt.Sprintf("/api/cards/%d/reactions", id), bobID, fiber.Map{"emoji": "👍"}},
+		{"status", "PATCH", fmt.Sprintf("/api/cards/%d/status", id), adminID, fiber.Map{"status": "fixed"}},
+		{"new card", "POST", "/api/cards", bobID, fiber.Map{"title": "Typo", "type": "issue"}},
+	}
+	for _, change := range changes {
+		r := ts.request(t, change.method, change.path, change.userID, change.body)
+		if r.status >= 300 {
+			t.Fatalf("%s: status %d, body %s", change.name, r.status, r.body)
+		}
+		r = ts.conditionalGet(t, path, aliceID, tag)
+		expect(t, r, 200)
+		if r.header.Get("ETag") == tag {
+			t.Errorf("after %s: ETag unchanged", change.name)
+		}
+		tag = r.header.Get("ETag")
+	}
+
+	// Lists share one version, so a change outside the filter changes their tags too,
+	// and so does deleting a card
+	filtered := "/api/cards?type=suggestion"
+	tag = ts.request(t, "GET", filtered, aliceID, nil).header.Get("ETag")
+	expect(t, ts.conditionalGet(t, filtered, aliceID, tag), 304)
+	ts.createComment(t, bobID, id, "Same here", false)
+	expect(t, ts.conditionalGet(t, filtered, aliceID, tag), 200)
+	tag = ts.request(t, "GET", path, aliceID, nil).header.Get("ETag")
+	expect(t, ts.request(t, "DELETE", fmt.Sprintf("/api/cards/%d", id), adminID, nil), 200)
+	expect(t, ts.conditionalGet(t, path, aliceID, tag), 200)
+}
+
+func TestCardETag(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.createCard(t, aliceID, "issue", "Crash", "")
+	comment := ts.createComment(t, adminID, id, "Fixed in 1.2", false)
+	path := fmt.Sprintf("/api/cards/%d", id)
+
+	r := ts.request(t, "GET", path, anonymous, nil)
+	expect(t, r, 200)
+	tag := r.header.Get("ETag")
+	if cc := r.header.Get("Cache-Control"); cc != "public, no-cache" {
+		t.Errorf("Cache-Control = %q", cc)
+	}
+	expect(t, ts.conditionalGet(t, path, anonymous, tag), 304)
+
+	// Staff see internal comments counted, so they get their own tag
+	expect(t, ts.conditionalGet(t, path, adminID, tag), 200)
+
+	changes := []struct {
+		name   string
+		method string
+		path   string
+		userID int64
+		body   interface{}
+	}{
+		{"comment", "POST", path + "/comments", bobID, fiber.Map{"content": "Same here"}},
+		{"comment reaction", "POST", fmt.Sprintf("/api/comments/%d/reactions", comment), bobID, fiber.Map{"emoji": "🎉"}},
+		{"official response", "PATCH", fmt.Sprintf("/api/comments/%d/official", comment), adminID, fiber.Map{"official": true}},
+		{"removed reaction", "DELETE", fmt.Sprintf("/api/comments/%d/reactions?emoji=🎉", comment), bobID, nil},
+	}
+	for _, change := range changes {
+		r := ts.request(t, change.method, change.path, change.userID, change.body)
+		if r.status >= 300 {
+			t.Fatalf("%s: status %d, body %s", change.name, r.status, r.body)
+		}
+		r = ts.conditionalGet(t, path, anonymous, tag)
+		expect(t, r, 200)
+		if r.header.Get("ETag") == tag {
+			t.Errorf("after %s: ETag unchanged", change.name)
+		}
+		tag = r.header.Get("ETag")
+	}
+
+	// Setting an unchanged flag is not a change
+	expect(t, ts.request(t, "PATCH", fmt.Sprintf("/api/comments/%d/official", comment), adminID, fiber.Map{"official": true}), 200)
+	expect(t, ts.conditionalGet(t, path, anonymous, tag), 304)
+
+	r = ts.conditionalGet(t, "/api/cards/999", anonymous, tag)
+	expect(t, r, 404)
+	if r.header.Get("ETag") != "" {
+		t.Errorf("404 has ETag %q", r.header.Get("ETag"))
+	}
+}
+
+// failingReactions is a store whose reaction lookups fail
+type failingReactions struct {
+	repository.Store
+}
+
+func (failingReactions) GetCardReactions(ctx context.Context, cardIDs []int64, userID int64) (map[int64][]models.Reaction, error) {
+	return nil, errors.New("connection reset")
+}
+
+func (failingReactions) GetCommentReactions(ctx context.Context, commentIDs []int64, userID int64) (map[int64][]models.Reaction, error) {
+	return nil, errors.New("connection reset")
+}
+
+// A failure after the version check is an error, never a tagged response with parts missing
+func TestETagOnlyOnCompleteResponses(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.createCard(t, aliceID, "issue", "Crash", "")
+	ts.h.repo = failingReactions{ts.store}
+
+	for _, path := range []string{"/api/cards", fmt.Sprintf("/api/cards/%d", id)} {
+		r := ts.request(t, "GET", path, aliceID, nil)
+		expect(t, r, 500)
+		if tag := r.header.Get("ETag"); tag != "" {
+			t.Errorf("GET %s: ETag = %q on an error", path, tag)
+		}
+	}
+}
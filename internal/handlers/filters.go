@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"bugtracker/internal/repository"
+	"bugtracker/internal/search"
+)
+
+// cardFilter builds the repository filter for the card list from the type, status and tags
+// parameters and the search box query (see package search). Parameters and query terms
+// are combined with AND; ok is false when they contradict each other and nothing can match.
+func cardFilter(cardType, status, query string, tags []string) (filter repository.CardFilter, ok bool, err error) {
+	parsed, err := search.Parse(query)
+	if err != nil {
+		return filter, false, err
+	}
+
+	var types, statuses []string
+	for _, f := range parsed.Filters {
+		switch f.Field {
+		case search.FieldType:
+			types = append(types, f.Value)
+		case search.FieldStatus:
+			statuses = append(statuses, f.Value)
+		case search.FieldTag:
+			filter.Tags = append(filter.Tags, f.Value)
+		case search.FieldAuthor:
+			filter.Authors = append(filter.Authors, f.Value)
+		case search.FieldVotes:
+			applyRatingBound(&filter, f.Op, f.Int)
+		case search.FieldCreated:
+			applyCreatedBound(&filter, f)
+		}
+	}
+
+	if filter.Types, ok = combine(types, cardType); !ok {
+		return filter, false, nil
+	}
+	if filter.Statuses, ok = combine(statuses, status); !ok {
+		return filter, false, nil
+	}
+	filter.Tags = append(filter.Tags, tags...)
+	filter.Query = parsed.FullText()
+	return filter, true, nil
+}
+
+// cleanTags trims tag names and drops empty and repeated ones. The result is never nil,
+// since saved views store it in a NOT NULL column.
+func cleanTags(tags []string) []string {
+	out := []string{}
+	seen := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		key := strings.ToLower(t)
+		if t == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, t)
+	}
+	return out
+}
+
+// combine ANDs a single request parameter with the alternatives from the query
+func combine(values []string, param string) ([]string, bool) {
+	if param == "" {
+		return values, true
+	}
+	if len(values) == 0 {
+		return []string{param}, true
+	}
+	for _, v := range values {
+		if strings.EqualFold(v, param) {
+			return []string{param}, true
+		}
+	}
+	return nil, false
+}
+
+func applyRatingBound(filter *repository.CardFilter, op search.Op, n int) {
+	lo, hi := filter.MinRating, filter.MaxRating
+	setMin := func(v int) {
+		if lo == nil || v > *lo {
+			lo = &v
+		}
+	}
+	setMax := func(v int) {
+		if hi == nil || v < *hi {
+			hi = &v
+		}
+	}
+
+	switch op {
+	case search.OpEq:
+		setMin(n)
+		setMax(n)
+	case search.OpGt:
+		setMin(n + 1)
+	case search.OpGte:
+		setMin(n)
+	case search.OpLt:
+		setMax(n - 1)
+	case search.OpLte:
+		setMax(n)
+	}
+	filter.MinRating, filter.MaxRating = lo, hi
+}
+
+// applyCreatedBound narrows the creation time range to whole days
+func applyCreatedBound(filter *repository.CardFilter, f search.Filter) {
+	day, nextDay := f.Date, f.Date.AddDate(0, 0, 1)
+	setFrom := func(t time.Time) {
+		if filter.CreatedFrom.IsZero() || t.After(filter.CreatedFrom) {
+			filter.CreatedFrom = t
+		}
+	}
+	setTo := func(t time.Time) {
+		if filter.CreatedTo.IsZero() || t.Before(filter.CreatedTo) {
+			filter.CreatedTo = t
+		}
+	}
+
+	switch f.Op {
+	case search.OpEq:
+		setFrom(day)
+		setTo(nextDay)
+	case search.OpGt:
+		setFrom(nextDay)
+	case search.OpGte:
+		setFrom(day)
+	case search.OpLt:
+		setTo(day)
+	case search.OpLte:
+		setTo(nextDay)
+	}
+}
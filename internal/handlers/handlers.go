@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"log"
 	"strconv"
 	"time"
@@ -17,19 +18,23 @@ import (
 )
 
 type Handler struct {
-	repo     *repository.Repository
+	repo     repository.Store
 	cfg      *config.Config
 	imgbb    *imgbb.Client
-	telegram *telegram.Client
+	telegram messenger
 	s3       *s3.Client
+	// Wakes RunNotifier when notifications were queued
+	notifierWake chan struct{}
 }
 
-func New(repo *repository.Repository, cfg *config.Config) *Handler {
+func New(repo repository.Store, cfg *config.Config) *Handler {
 	h := &Handler{
 		repo:     repo,
 		cfg:      cfg,
 		imgbb:    imgbb.New(cfg.ImgBBApiKey),
 		telegram: telegram.New(cfg.BotToken),
+
+		notifierWake: make(chan struct{}, 1),
 	}
 
 	if cfg.S3Bucket != "" {
@@ -52,12 +57,68 @@ func New(repo *repository.Repository, cfg *config.Config) *Handler {
 	return h
 }
 
+// TimeoutMiddleware gives each request a deadline through its user context, which
+// repository calls run under. Only the deadline is enforced: fasthttp does not tell
+// when a client disconnects, so a request it left keeps running until it ends or times out.
+func (h *Handler) TimeoutMiddleware(c *fiber.Ctx) error {
+	if h.cfg.RequestTimeout <= 0 {
+		return c.Next()
+	}
+	ctx, cancel := context.WithTimeout(c.UserContext(), h.cfg.RequestTimeout)
+	defer cancel()
+	c.SetUserContext(ctx)
+	return c.Next()
+}
+
+// readPrimaryCookie marks a client that wrote recently (see ReadYourWritesMiddleware)
+const readPrimaryCookie = "read_primary"
+
+// ReadYourWritesMiddleware keeps a request's repository reads on the primary when a
+// replica is configured and the request writes, or its client wrote within
+// ReplicaStickiness: the replica may not have the client's own changes yet.
+func (h *Handler) ReadYourWritesMiddleware(c *fiber.Ctx) error {
+	if h.cfg.DatabaseReplicaURL == "" {
+		return c.Next()
+	}
+	write := c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead && c.Method() != fiber.MethodOptions
+	if write || c.Cookies(readPrimaryCookie) != "" {
+		c.SetUserContext(repository.Primary(c.UserContext()))
+	}
+
+	err := c.Next()
+	if write && err == nil && c.Response().StatusCode() < 400 && h.cfg.ReplicaStickiness > 0 {
+		maxAge := int((h.cfg.ReplicaStickiness + time.Second - 1) / time.Second)
+		c.Cookie(&fiber.Cookie{
+			Name:     readPrimaryCookie,
+			Value:    "1",
+			Path:     "/",
+			MaxAge:   maxAge,
+			HTTPOnly: true,
+			SameSite: "Lax",
+		})
+	}
+	return err
+}
+
+// dbError answers a failed repository call. Queries cut short by the request deadline
+// or the statement timeout are logged and reported apart from failures.
+func (h *Handler) dbError(c *fiber.Ctx, err error, message string) error {
+	if repository.Interruption(c.UserContext(), err) == repository.ErrTimeout {
+		log.Printf("Request timed out: %s %s", c.Method(), c.Path())
+		return c.Status(503).JSON(fiber.Map{"error": "Request timed out, try again"})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": message})
+}
+
 // Auth middleware
 func (h *Handler) AuthMiddleware(c *fiber.Ctx) error {
 	sessionID := c.Cookies("session_id")
 	if sessionID != "" {
 		userID, _ := strconv.ParseInt(sessionID, 10, 64)
-		user, _ := h.repo.GetUser(userID)
+		user, err := h.repo.GetUser(c.UserContext(), userID)
+		if err != nil {
+			return h.dbError(c, err, "Error loading session")
+		}
 		if user != nil {
 			c.Locals("user", user)
 		}
@@ -85,8 +146,8 @@ func (h *Handler) TelegramAuth(c *fiber.Ctx) error {
 		AuthDate:  time.Unix(data.AuthDate, 0),
 	}
 
-	if err := h.repo.UpsertUser(user); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Database error"})
+	if err := h.repo.UpsertUser(c.UserContext(), user); err != nil {
+		return h.dbError(c, err, "Database error")
 	}
 
 	c.Cookie(&fiber.Cookie{
@@ -132,7 +193,7 @@ func (h *Handler) CreateCard(c *fiber.Ctx) error {
 		return c.Status(400).SendString("Title is required")
 	}
 
-	if err := h.repo.CreateCard(card); err != nil {
+	if err := h.repo.CreateCard(c.UserContext(), card, nil, nil); err != nil {
 		return c.Status(500).SendString("Error creating card")
 	}
 
@@ -0,0 +1,383 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"bugtracker/internal/config"
+	"bugtracker/internal/models"
+	"bugtracker/internal/repository/memory"
+)
+
+// Handler tests run the API from Routes on the in-memory store through app.Test.
+// Every registered route must be requested by at least one test (see TestMain).
+
+const testBotToken = "test-bot-token"
+
+// Test users; adminID is staff
+const (
+	adminID int64 = 1
+	aliceID int64 = 2
+	bobID   int64 = 3
+)
+
+// anonymous makes a request without a session
+const anonymous int64 = 0
+
+// routesHit records "METHOD /path" of every route a test requested
+var routesHit sync.Map
+
+func recordRoute(c *fiber.Ctx) error {
+	err := c.Next()
+	routesHit.Store(c.Method()+" "+c.Route().Path, true)
+	return err
+}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	code := m.Run()
+	// Only a full run is expected to cover every route
+	if code == 0 && flag.Lookup("test.run").Value.String() == "" {
+		if missing := uncoveredRoutes(); len(missing) > 0 {
+			fmt.Println("Routes not covered by any test:\n  " + strings.Join(missing, "\n  "))
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+func uncoveredRoutes() []string {
+	app := fiber.New()
+	New(memory.New(), &config.Config{}).Routes(app.Group("/api"))
+
+	var missing []string
+	for _, r := range app.GetRoutes(true) {
+		key := r.Method + " " + r.Path
+		if r.Method == fiber.MethodHead {
+			continue
+		}
+		if _, ok := routesHit.Load(key); !ok {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+type testServer struct {
+	app   *fiber.App
+	cfg   *config.Config
+	store *memory.Store
+	h     *Handler
+	// Telegram messages sent by the notifier
+	sent *fakeMessenger
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	cfg := &config.Config{
+		BotToken:             testBotToken,
+		AdminIDs:             []int64{adminID},
+		AppURL:               "https://bugs.example.com",
+		RequestTimeout:       5 * time.Second,
+		ReactionEmojis:       []string{"👍", "🎉"},
+		SimilarMinSimilarity: 0.3,
+		SimilarMinRank:       0.1,
+		SimilarLimit:         5,
+		VoteBudgetPeriod:     "month",
+		NotifyPollInterval:   time.Hour, // tests deliver notifications themselves
+		NotifyMaxAttempts:    3,
+	}
+	store := memory.New()
+	users := []*models.User{
+		{ID: adminID, FirstName: "Admin", Username: "admin"},
+		{ID: aliceID, FirstName: "Alice", Username: "alice"},
+		{ID: bobID, FirstName: "Bob", Username: "bob"},
+	}
+	for _, u := range users {
+		if err := store.UpsertUser(context.Background(), u); err != nil {
+			t.Fatal(err)
+		}
+		store.SetUserCreatedAt(u.ID, time.Now().AddDate(0, -1, 0))
+	}
+
+	h := New(store, cfg)
+	sent := &fakeMessenger{}
+	h.telegram = sent // never reach the Telegram API from tests
+
+	app := fiber.New()
+	app.Use(recordRoute)
+	app.Use(h.TimeoutMiddleware)
+	app.Use(h.ReadYourWritesMiddleware)
+	app.Use(h.AuthMiddleware)
+	h.Routes(app.Group("/api"))
+
+	return &testServer{app: app, cfg: cfg, store: store, h: h, sent: sent}
+}
+
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// decode unmarshals the JSON body into v
+func (r response) decode(t *testing.T, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(r.body, v); err != nil {
+		t.Fatalf("decoding %s: %v", r.body, err)
+	}
+}
+
+// errorMessage returns the "error" field of the body
+func (r response) errorMessage(t *testing.T) string {
+	t.Helper()
+	var body struct {
+		Error string `json:"error"`
+	}
+	r.decode(t, &body)
+	return body.Error
+}
+
+// send runs req as the given user (anonymous for none)
+func (ts *testServer) send(t *testing.T, req *http.Request, userID int64) response {
+	t.Helper()
+	if userID != anonymous {
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: strconv.FormatInt(userID, 10)})
+	}
+	resp, err := ts.app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", req.Method, req.URL, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response{status: resp.StatusCode, header: resp.Header, body: body}
+}
+
+// request sends body, if any, as JSON
+func (ts *testServer) request(t *testing.T, method, path string, userID int64, body interface{}) response {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return ts.send(t, req, userID)
+}
+
+// expect fails the test unless the response has the given status
+func expect(t *testing.T, r response, status int) {
+	t.Helper()
+	if r.status != status {
+		t.Fatalf("status = %d, want %d; body %s", r.status, status, r.body)
+	}
+}
+
+// createCard creates a card through the API and returns its ID
+func (ts *testServer) createCard(t *testing.T, userID int64, cardType, title, description string) int64 {
+	t.Helper()
+	r := ts.request(t, "POST", "/api/cards", userID, fiber.Map{"title": title, "description": description, "type": cardType})
+	expect(t, r, 201)
+	var card models.Card
+	r.decode(t, &card)
+	return card.ID
+}
+
+// createComment comments on a card through the API and returns the comment's ID
+func (ts *testServer) createComment(t *testing.T, userID, cardID int64, content string, internal bool) int64 {
+	t.Helper()
+	r := ts.request(t, "POST", fmt.Sprintf("/api/cards/%d/comments", cardID), userID, fiber.Map{"content": content, "internal": internal})
+	expect(t, r, 201)
+	var comment models.Comment
+	r.decode(t, &comment)
+	return comment.ID
+}
+
+// eventually retries check until it passes, for work done after the response
+func eventually(t *testing.T, check func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !check() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestGetConfig(t *testing.T) {
+	ts := newTestServer(t)
+	ts.cfg.BotUsername = "bugs_bot"
+
+	r := ts.request(t, "GET", "/api/config", anonymous, nil)
+	expect(t, r, 200)
+	var body struct {
+		BotUsername    string   `json:"bot_username"`
+		ReactionEmojis []string `json:"reaction_emojis"`
+	}
+	r.decode(t, &body)
+	if body.BotUsername != "bugs_bot" || len(body.ReactionEmojis) != 2 {
+		t.Errorf("config = %+v", body)
+	}
+}
+
+func TestGetMe(t *testing.T) {
+	ts := newTestServer(t)
+
+	expect(t, ts.request(t, "GET", "/api/auth/me", anonymous, nil), 401)
+	expect(t, ts.request(t, "GET", "/api/auth/me", 999, nil), 401)
+
+	for _, tt := range []struct {
+		userID int64
+		admin  bool
+	}{{adminID, true}, {aliceID, false}} {
+		r := ts.request(t, "GET", "/api/auth/me", tt.userID, nil)
+		expect(t, r, 200)
+		var me models.User
+		r.decode(t, &me)
+		if me.ID != tt.userID || me.IsAdmin != tt.admin {
+			t.Errorf("me = %+v, want id %d admin %v", me, tt.userID, tt.admin)
+		}
+	}
+}
+
+// signTelegramAuth signs login data the way the Telegram login widget does
+func signTelegramAuth(data *models.TelegramAuthData) {
+	check := fmt.Sprintf("auth_date=%d\nfirst_name=%s\nid=%d\nusername=%s", data.AuthDate, data.FirstName, data.ID, data.Username)
+	secret := sha256.Sum256([]byte(testBotToken))
+	mac := hmac.New(sha256.New, secret[:])
+	mac.Write([]byte(check))
+	data.Hash = hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestTelegramAuth(t *testing.T) {
+	ts := newTestServer(t)
+
+	data := models.TelegramAuthData{ID: 42, FirstName: "Carol", Username: "carol", AuthDate: time.Now().Unix()}
+	signTelegramAuth(&data)
+
+	forged := data
+	forged.ID = adminID
+	expect(t, ts.request(t, "POST", "/api/auth/telegram", anonymous, forged), 401)
+
+	r := ts.request(t, "POST", "/api/auth/telegram", anonymous, data)
+	expect(t, r, 200)
+	if cookie := r.header.Get("Set-Cookie"); !strings.Contains(cookie, "session_id=42") {
+		t.Errorf("Set-Cookie = %q, want the session of user 42", cookie)
+	}
+
+	r = ts.request(t, "GET", "/api/auth/me", 42, nil)
+	expect(t, r, 200)
+	var me models.User
+	r.decode(t, &me)
+	if me.Username != "carol" {
+		t.Errorf("me = %+v", me)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	ts := newTestServer(t)
+
+	r := ts.request(t, "POST", "/api/auth/logout", aliceID, nil)
+	expect(t, r, 200)
+	if cookie := r.header.Get("Set-Cookie"); !strings.Contains(cookie, "session_id=;") {
+		t.Errorf("Set-Cookie = %q, want the session cleared", cookie)
+	}
+}
+
+// multipartRequest builds a file upload with a single file field
+func multipartRequest(t *testing.T, path, field, filename string, content []byte) *http.Request {
+	t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if field != "" {
+		part, err := w.CreateFormFile(field, filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(content)
+	}
+	w.Close()
+	req := httptest.NewRequest("POST", path, &buf)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+func TestUploadFile(t *testing.T) {
+	ts := newTestServer(t)
+
+	expect(t, ts.send(t, multipartRequest(t, "/api/upload", "file", "a.png", []byte("png")), anonymous), 401)
+	expect(t, ts.send(t, multipartRequest(t, "/api/upload", "", "", nil), aliceID), 400)
+
+	r := ts.send(t, multipartRequest(t, "/api/upload", "file", "a.png", []byte("png")), aliceID)
+	expect(t, r, 500)
+	if msg := r.errorMessage(t); msg != "File storage not configured" {
+		t.Errorf("error = %q", msg)
+	}
+}
+
+func TestUploadImage(t *testing.T) {
+	ts := newTestServer(t)
+
+	expect(t, ts.send(t, multipartRequest(t, "/api/upload/image", "image", "a.png", []byte("png")), anonymous), 401)
+	expect(t, ts.send(t, multipartRequest(t, "/api/upload/image", "", "", nil), aliceID), 400)
+
+	// Without an ImgBB key the upload fails before any request is made
+	r := ts.send(t, multipartRequest(t, "/api/upload/image", "image", "a.png", []byte("png")), aliceID)
+	expect(t, r, 500)
+	if msg := r.errorMessage(t); !strings.Contains(msg, "not configured") {
+		t.Errorf("error = %q", msg)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	ts := newTestServer(t)
+	card := ts.createCard(t, aliceID, "issue", "Crash on start", "The app crashes")
+	comment := ts.createComment(t, aliceID, card, "Same here", false)
+	ts.cfg.RequestTimeout = time.Nanosecond
+
+	// Timeouts are reported as such, not as missing cards or comments
+	for _, tt := range []struct{ method, path string }{
+		{"GET", "/api/cards"},
+		{"GET", fmt.Sprintf("/api/cards/%d", card)},
+		{"GET", fmt.Sprintf("/api/cards/%d/votes", card)},
+		{"POST", fmt.Sprintf("/api/cards/%d/vote", card)},
+		{"POST", fmt.Sprintf("/api/cards/%d/reactions", card)},
+		{"POST", fmt.Sprintf("/api/comments/%d/reactions", comment)},
+	} {
+		r := ts.request(t, tt.method, tt.path, bobID, fiber.Map{"value": 1, "emoji": "👍"})
+		expect(t, r, 503)
+		if msg := r.errorMessage(t); msg != "Request timed out, try again" {
+			t.Errorf("%s %s: error = %q", tt.method, tt.path, msg)
+		}
+	}
+}
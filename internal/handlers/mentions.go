@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"bugtracker/internal/models"
+)
+
+// mentionPattern matches @username that is not part of an e-mail address or another word.
+var mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_@])@([A-Za-z0-9_]{1,32})`)
+
+// parseMentions returns the unique usernames mentioned in text, in order of appearance.
+func parseMentions(text string) []string {
+	seen := make(map[string]bool)
+	var usernames []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		key := strings.ToLower(match[1])
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		usernames = append(usernames, match[1])
+	}
+	return usernames
+}
+
+// mentionedUsers resolves the @mentions in text written by author to user IDs, leaving
+// out the author, and users who are not staff when staffOnly is set (as for internal notes)
+func (h *Handler) mentionedUsers(ctx context.Context, text string, author *models.User, staffOnly bool) ([]int64, error) {
+	usernames := parseMentions(text)
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+
+	users, err := h.repo.GetUsersByUsernames(ctx, usernames)
+	if err != nil {
+		return nil, err
+	}
+
+	var userIDs []int64
+	for _, u := range users {
+		if u.ID == author.ID || (staffOnly && !h.cfg.IsAdmin(u.ID)) {
+			continue
+		}
+		userIDs = append(userIDs, u.ID)
+	}
+	return userIDs, nil
+}
+
+// cardLink returns an HTML deep link to a card (and optionally a comment on it)
+// for Telegram messages, or an empty string when APP_URL is not configured.
+func (h *Handler) cardLink(cardID, commentID int64) string {
+	if h.cfg.AppURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n\n<a href=\"%s\">Открыть карточку</a>", h.cfg.AppURL+cardPath(cardID, commentID))
+}
+
+// cardPath is the frontend path of a card, anchored at a comment if commentID is set
+func cardPath(cardID, commentID int64) string {
+	path := fmt.Sprintf("/c/%d", cardID)
+	if commentID != 0 {
+		path += fmt.Sprintf("#comment-%d", commentID)
+	}
+	return path
+}
+
+// GetMyMentions returns cards and comments where the current user was mentioned
+func (h *Handler) GetMyMentions(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login required"})
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+
+	mentions, total, err := h.repo.ListMentions(c.UserContext(), user.ID, limit, offset)
+	if err != nil {
+		return h.dbError(c, err, "Error loading mentions")
+	}
+
+	return c.JSON(fiber.Map{
+		"mentions": mentions,
+		"total":    total,
+		"has_more": offset+len(mentions) < total,
+	})
+}
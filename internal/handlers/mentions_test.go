@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+
+	"bugtracker/internal/models"
+)
+
+func TestParseMentions(t *testing.T) {
+	got := parseMentions("@alice and @Bob, mail bob@example.com, again @ALICE")
+	if fmt.Sprint(got) != "[alice Bob]" {
+		t.Errorf("parseMentions = %v", got)
+	}
+}
+
+func TestMyMentions(t *testing.T) {
+	ts := newTestServer(t)
+	expect(t, ts.request(t, "GET", "/api/me/mentions", anonymous, nil), 401)
+
+	id := ts.createCard(t, aliceID, "issue", "Crash", "Ping @bob")
+	commentID := ts.createComment(t, aliceID, id, "@bob @admin any news?", false)
+	// Internal notes only mention staff
+	ts.createComment(t, adminID, id, "@bob @alice internal", true)
+
+	mentions := func(userID int64) []*models.Mention {
+		r := ts.request(t, "GET", "/api/me/mentions", userID, nil)
+		expect(t, r, 200)
+		var body struct {
+			Mentions []*models.Mention `json:"mentions"`
+		}
+		r.decode(t, &body)
+		return body.Mentions
+	}
+	got := mentions(bobID)
+	if len(got) != 2 || len(mentions(adminID)) != 1 {
+		t.Fatalf("bob's mentions = %d, admin's = %d", len(got), len(mentions(adminID)))
+	}
+	if got[0].CommentID != commentID || got[0].Content != "@bob @admin any news?" || got[1].CommentID != 0 || got[1].Content != "Ping @bob" {
+		t.Errorf("bob's mentions = %+v, %+v", got[0], got[1])
+	}
+	if len(mentions(aliceID)) != 0 {
+		t.Error("alice was mentioned in an internal note")
+	}
+
+	// Mentions go with the card
+	expect(t, ts.request(t, "DELETE", fmt.Sprintf("/api/cards/%d", id), adminID, nil), 200)
+	if got := mentions(bobID); len(got) != 0 {
+		t.Errorf("mentions of a deleted card = %+v", got)
+	}
+}
@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"bugtracker/internal/models"
+	"bugtracker/internal/repository"
+)
+
+// Notifications go through the outbox: handlers decide who to tell about a change and
+// the repository stores those notifications in the change's transaction. RunNotifier
+// delivers them as the recipients' preferences allow (see preferences.go), rendering
+// each message from the current state of its card, and retries failed deliveries with
+// exponential backoff until they become dead letters, which admins can inspect and
+// retry. Delivery is at least once: a message can be sent twice if the process stops
+// between sending it and removing it from the outbox.
+
+// Notification events
+const (
+	eventComment = "comment" // a new comment on the recipient's card
+	eventMention = "mention" // the recipient was mentioned in a card or comment
+	eventStatus  = "status"  // the status of the recipient's card changed
+	// Cards have no assignees or milestones yet; users can already choose whether to
+	// hear about them, but nothing sends these events
+	eventAssignment = "assignment"
+	eventMilestone  = "milestone"
+)
+
+const (
+	notifyBatchSize = 20
+	// A claimed notification is retried after notifyLease if its delivery never finishes
+	notifyLease = time.Minute
+)
+
+// messenger sends chat messages; *telegram.Client implements it
+type messenger interface {
+	SendMessage(chatID int64, text string) error
+}
+
+// commentNotifications decides who to tell about a new comment: the card author, unless
+// they wrote it or it is an internal note, and the mentioned users. The card author
+// hears about the comment rather than about being mentioned in it.
+func commentNotifications(card *models.Card, commenter *models.User, internal bool, mentioned []int64) []*models.Notification {
+	var notify []*models.Notification
+	var skip int64
+	if !internal {
+		skip = card.UserID
+		if card.UserID != commenter.ID {
+			notify = append(notify, &models.Notification{
+				UserID:  card.UserID,
+				Event:   eventComment,
+				CardID:  card.ID,
+				ActorID: commenter.ID,
+			})
+		}
+	}
+	return append(notify, mentionNotifications(card.ID, commenter, mentioned, skip)...)
+}
+
+// mentionNotifications tells the mentioned users, except skipUserID, that author mentioned them
+func mentionNotifications(cardID int64, author *models.User, mentioned []int64, skipUserID int64) []*models.Notification {
+	var notify []*models.Notification
+	for _, userID := range mentioned {
+		if userID == skipUserID {
+			continue
+		}
+		notify = append(notify, &models.Notification{
+			UserID:  userID,
+			Event:   eventMention,
+			CardID:  cardID,
+			ActorID: author.ID,
+		})
+	}
+	return notify
+}
+
+// wakeNotifier makes RunNotifier look for due notifications now rather than at its next poll
+func (h *Handler) wakeNotifier() {
+	select {
+	case h.notifierWake <- struct{}{}:
+	default:
+	}
+}
+
+// RunNotifier delivers notifications from the outbox until ctx is canceled
+func (h *Handler) RunNotifier(ctx context.Context) {
+	interval := h.cfg.NotifyPollInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		// A full batch means more may be due
+		for h.deliverNotifications(ctx) == notifyBatchSize {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-h.notifierWake:
+		}
+	}
+}
+
+// deliverNotifications claims a batch of due notifications, delivers them and returns
+// how many it claimed
+func (h *Handler) deliverNotifications(ctx context.Context) int {
+	// The outbox and the rows its messages are made from were just written on the primary
+	ctx = repository.Primary(ctx)
+	batch, err := h.repo.ClaimNotifications(ctx, notifyBatchSize, notifyLease)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Failed to claim notifications: %v", err)
+		}
+		return 0
+	}
+	for _, n := range batch {
+		h.deliverNotification(ctx, n)
+	}
+	return len(batch)
+}
+
+func (h *Handler) deliverNotification(ctx context.Context, n *models.Notification) {
+	quietUntil, err := h.sendNotification(ctx, n)
+	if err == nil && !quietUntil.IsZero() {
+		if err := h.repo.DeferNotification(ctx, n.ID, quietUntil); err != nil {
+			log.Printf("Failed to defer notification %d: %v", n.ID, err)
+		}
+		return
+	}
+	if err == nil {
+		if err := h.repo.DeleteNotification(ctx, n.ID); err != nil {
+			log.Printf("Failed to remove delivered notification %d: %v", n.ID, err)
+		}
+		return
+	}
+
+	var retryAt time.Time
+	if n.Attempts < h.cfg.NotifyMaxAttempts {
+		retryAt = time.Now().Add(h.notifyBackoff(n.Attempts))
+		log.Printf("Failed to send %s notification %d to user %d (attempt %d), retrying at %s: %v",
+			n.Event, n.ID, n.UserID, n.Attempts, retryAt.Format(time.RFC3339), err)
+	} else {
+		log.Printf("Failed to send %s notification %d to user %d, giving up after %d attempts: %v",
+			n.Event, n.ID, n.UserID, n.Attempts, err)
+	}
+	if err := h.repo.FailNotification(ctx, n.ID, err.Error(), retryAt); err != nil {
+		log.Printf("Failed to record failure of notification %d: %v", n.ID, err)
+	}
+}
+
+// sendNotification sends a notification unless its recipient muted the event. During
+// the recipient's quiet hours it sends nothing and returns when they end.
+func (h *Handler) sendNotification(ctx context.Context, n *models.Notification) (time.Time, error) {
+	prefs, err := h.notificationPreferences(ctx, n.UserID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if isMuted(prefs, n.Event, channelTelegram) {
+		return time.Time{}, nil
+	}
+	if until, quiet := quietHoursEnd(prefs, time.Now()); quiet {
+		return until, nil
+	}
+
+	message, err := h.renderNotification(ctx, n)
+	if err != nil || message == "" {
+		return time.Time{}, err
+	}
+	return time.Time{}, h.telegram.SendMessage(n.UserID, message)
+}
+
+// notifyBackoff is the delay before the next attempt after the given number of attempts:
+// NotifyRetryBase, doubled for every further attempt, at most NotifyRetryMax
+func (h *Handler) notifyBackoff(attempts int) time.Duration {
+	d, max := h.cfg.NotifyRetryBase, h.cfg.NotifyRetryMax
+	for i := 1; i < attempts && (max <= 0 || d < max); i++ {
+		d *= 2
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	return d
+}
+
+// renderNotification builds the message of a notification from the current state of its
+// card. The message is empty when there is nothing left to tell, e.g. the card is gone.
+func (h *Handler) renderNotification(ctx context.Context, n *models.Notification) (string, error) {
+	card, err := h.repo.GetCard(ctx, n.CardID, false)
+	if err != nil || card == nil {
+		return "", err
+	}
+
+	var comment *models.Comment
+	if n.CommentID != 0 {
+		if comment, err = h.repo.GetComment(ctx, n.CommentID); err != nil || comment == nil {
+			return "", err
+		}
+	}
+
+	actor := &models.User{}
+	if n.ActorID != 0 {
+		u, err := h.repo.GetUser(ctx, n.ActorID)
+		if err != nil {
+			return "", err
+		}
+		if u != nil {
+			actor = u
+		}
+	}
+	actorName := actor.FirstName
+	if actor.LastName != "" {
+		actorName += " " + actor.LastName
+	}
+
+	// Messages are sent in HTML parse mode, where Telegram rejects a stray < or &, so
+	// user text is escaped, after truncating so that no entity is cut
+	switch n.Event {
+	case eventComment:
+		if comment == nil {
+			return "", nil
+		}
+		return fmt.Sprintf("💬 <b>Новый комментарий к вашей карточке</b>\n\n\"%s\"\n\n<b>%s</b>: %s%s",
+			html.EscapeString(card.Title), html.EscapeString(actorName), html.EscapeString(truncate(comment.Content, 200)),
+			h.cardLink(card.ID, comment.ID)), nil
+
+	case eventMention:
+		text := card.Description
+		if comment != nil {
+			text = comment.Content
+		}
+		return fmt.Sprintf("🔔 <b>Вас упомянули</b>\n\n\"%s\"\n\n<b>%s</b>: %s%s",
+			html.EscapeString(card.Title), html.EscapeString(actorName), html.EscapeString(truncate(text, 200)),
+			h.cardLink(card.ID, n.CommentID)), nil
+
+	case eventStatus:
+		return h.statusMessage(ctx, card, n.CardStatus)
+	}
+	return "", fmt.Errorf("unknown notification event %q", n.Event)
+}
+
+func (h *Handler) statusMessage(ctx context.Context, card *models.Card, newStatus string) (string, error) {
+	statusLabels := map[string]string{
+		"open":       "Open",
+		"closed":     "Closed",
+		"fixed":      "Fixed",
+		"fix_coming": "Fix Coming",
+	}
+
+	statusLabel := statusLabels[newStatus]
+	if statusLabel == "" {
+		statusLabel = newStatus
+	}
+
+	var response string
+	official, err := h.repo.GetOfficialResponses(ctx, card.ID)
+	if err != nil {
+		return "", err
+	}
+	if len(official) > 0 {
+		response = fmt.Sprintf("\n\n<b>Официальный ответ:</b> %s", html.EscapeString(truncate(official[0].Content, 300)))
+	}
+
+	return fmt.Sprintf("📋 <b>Статус вашей карточки изменен</b>\n\n\"%s\"\n\nНовый статус: <b>%s</b>%s%s",
+		html.EscapeString(card.Title), statusLabel, response, h.cardLink(card.ID, 0)), nil
+}
+
+// truncate shortens text longer than max characters for a message
+func truncate(text string, max int) string {
+	if runes := []rune(text); len(runes) > max {
+		return string(runes[:max]) + "..."
+	}
+	return text
+}
+
+// GetNotifications lists the notification outbox (admin only): dead letters by default,
+// or with state=pending the notifications waiting for delivery, with the error of
+// their last attempt if they failed before
+func (h *Handler) GetNotifications(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login required"})
+	}
+
+	if !h.cfg.IsAdmin(user.ID) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin access required"})
+	}
+
+	state := c.Query("state", repository.NotificationDead)
+	if state != repository.NotificationDead && state != repository.NotificationPending {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid state"})
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+	offset := (page - 1) * limit
+
+	notifications, total, err := h.repo.ListNotifications(c.UserContext(), state, limit, offset)
+	if err != nil {
+		return h.dbError(c, err, "Failed to load notifications")
+	}
+	if notifications == nil {
+		notifications = []*models.Notification{}
+	}
+
+	return c.JSON(fiber.Map{
+		"notifications": notifications,
+		"total":         total,
+		"has_more":      offset+len(notifications) < total,
+	})
+}
+
+// APIRetryNotification puts a dead notification back in the queue (admin only)
+func (h *Handler) APIRetryNotification(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login required"})
+	}
+
+	if !h.cfg.IsAdmin(user.ID) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin access required"})
+	}
+
+	id, _ := strconv.ParseInt(c.Params("id"), 10, 64)
+	retried, err := h.repo.RetryNotification(c.UserContext(), id)
+	if err != nil {
+		return h.dbError(c, err, "Failed to retry notification")
+	}
+	if !retried {
+		return c.Status(404).JSON(fiber.Map{"error": "Failed notification not found"})
+	}
+	h.wakeNotifier()
+
+	return c.JSON(fiber.Map{"ok": true})
+}
+
+// APIRetryNotifications puts every dead notification back in the queue (admin only)
+func (h *Handler) APIRetryNotifications(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login required"})
+	}
+
+	if !h.cfg.IsAdmin(user.ID) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin access required"})
+	}
+
+	retried, err := h.repo.RetryDeadNotifications(c.UserContext())
+	if err != nil {
+		return h.dbError(c, err, "Failed to retry notifications")
+	}
+	h.wakeNotifier()
+
+	return c.JSON(fiber.Map{"retried": retried})
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+
+	"bugtracker/internal/models"
+)
+
+// fakeMessenger records sent messages; while failing is set every send fails
+type fakeMessenger struct {
+	mu       sync.Mutex
+	messages map[int64][]string
+	failing  bool
+}
+
+func (m *fakeMessenger) SendMessage(chatID int64, text string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failing {
+		return errors.New("telegram is down")
+	}
+	if m.messages == nil {
+		m.messages = make(map[int64][]string)
+	}
+	m.messages[chatID] = append(m.messages[chatID], text)
+	return nil
+}
+
+func (m *fakeMessenger) setFailing(failing bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failing = failing
+}
+
+// take returns and forgets the messages sent to each user
+func (m *fakeMessenger) take() map[int64][]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	messages := m.messages
+	m.messages = nil
+	return messages
+}
+
+// deliver runs the notifier until the outbox has nothing due
+func (ts *testServer) deliver(t *testing.T) {
+	t.Helper()
+	for ts.h.deliverNotifications(context.Background()) > 0 {
+	}
+}
+
+func (ts *testServer) listNotifications(t *testing.T, query string) []*models.Notification {
+	t.Helper()
+	r := ts.request(t, "GET", "/api/admin/notifications"+query, adminID, nil)
+	expect(t, r, 200)
+	var body struct {
+		Notifications []*models.Notification `json:"notifications"`
+		Total         int                    `json:"total"`
+	}
+	r.decode(t, &body)
+	if body.Total != len(body.Notifications) {
+		t.Fatalf("total = %d for %d notifications", body.Total, len(body.Notifications))
+	}
+	return body.Notifications
+}
+
+func recipients(messages map[int64][]string) []int64 {
+	var ids []int64
+	for id := range messages {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func TestCommentNotifications(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.createCard(t, aliceID, "issue", "Crash", "Ping @bob")
+	ts.deliver(t)
+	if got := ts.sent.take()[bobID]; len(got) != 1 || !strings.Contains(got[0], "Вас упомянули") || !strings.Contains(got[0], "Ping @bob") {
+		t.Errorf("mention in a card = %q", got)
+	}
+
+	// The card author gets the comment, not the mention; the commenter gets nothing
+	commentID := ts.createComment(t, bobID, id, "@alice @admin have a look", false)
+	ts.deliver(t)
+	sent := ts.sent.take()
+	if fmt.Sprint(recipients(sent)) != fmt.Sprint([]int64{adminID, aliceID}) {
+		t.Fatalf("recipients = %v", recipients(sent))
+	}
+	link := fmt.Sprintf("https://bugs.example.com/c/%d#comment-%d", id, commentID)
+	if got := sent[aliceID]; len(got) != 1 || !strings.Contains(got[0], "Новый комментарий") || !strings.Contains(got[0], "<b>Bob</b>: @alice") || !strings.Contains(got[0], link) {
+		t.Errorf("comment notification = %q", got)
+	}
+	if got := sent[adminID]; len(got) != 1 || !strings.Contains(got[0], "Вас упомянули") || !strings.Contains(got[0], link) {
+		t.Errorf("mention in a comment = %q", got)
+	}
+
+	// Internal notes reach only mentioned staff
+	ts.createComment(t, adminID, id, "@alice @bob internal", true)
+	ts.deliver(t)
+	if sent := ts.sent.take(); len(sent) != 0 {
+		t.Errorf("internal note notified %v", recipients(sent))
+	}
+
+	// Notifications about deleted comments are dropped
+	commentID = ts.createComment(t, bobID, id, "oops", false)
+	expect(t, ts.request(t, "DELETE", fmt.Sprintf("/api/comments/%d", commentID), adminID, nil), 200)
+	ts.deliver(t)
+	if sent := ts.sent.take(); len(sent) != 0 {
+		t.Errorf("deleted comment notified %v", recipients(sent))
+	}
+}
+
+func TestStatusNotification(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.createCard(t, aliceID, "issue", "Crash", "")
+	commentID := ts.createComment(t, adminID, id, "Fixed in 1.2", false)
+	expect(t, ts.request(t, "PATCH", fmt.Sprintf("/api/comments/%d/official", commentID), adminID, fiber.Map{"official": true}), 200)
+	ts.deliver(t)
+	ts.sent.take()
+
+	expect(t, ts.request(t, "PATCH", fmt.Sprintf("/api/cards/%d/status", id), adminID, fiber.Map{"status": "fix_coming"}), 200)
+	ts.deliver(t)
+	got := ts.sent.take()[aliceID]
+	if len(got) != 1 || !strings.Contains(got[0], "Новый статус: <b>Fix Coming</b>") || !strings.Contains(got[0], "Официальный ответ:</b> Fixed in 1.2") {
+		t.Errorf("status notification = %q", got)
+	}
+
+	expect(t, ts.request(t, "PATCH", "/api/cards/999/status", adminID, fiber.Map{"status": "fixed"}), 404)
+}
+
+func TestNotificationEscaping(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.createCard(t, aliceID, "issue", "Crash in <b>", "")
+	ts.createComment(t, bobID, id, "@admin if a <b && c > 0", false)
+	ts.deliver(t)
+	sent := ts.sent.take()
+	for _, userID := range []int64{aliceID, adminID} {
+		got := sent[userID]
+		if len(got) != 1 || !strings.Contains(got[0], `"Crash in &lt;b&gt;"`) || !strings.Contains(got[0], "if a &lt;b &amp;&amp; c &gt; 0") {
+			t.Errorf("message to %d = %q", userID, got)
+		}
+	}
+
+	// The official response is cut at 300 characters before escaping, so no entity is cut
+	response := strings.Repeat("a", 299) + "&b"
+	commentID := ts.createComment(t, adminID, id, response, false)
+	expect(t, ts.request(t, "PATCH", fmt.Sprintf("/api/comments/%d/official", commentID), adminID, fiber.Map{"official": true}), 200)
+	ts.deliver(t)
+	ts.sent.take()
+	expect(t, ts.request(t, "PATCH", fmt.Sprintf("/api/cards/%d/status", id), adminID, fiber.Map{"status": "fixed"}), 200)
+	ts.deliver(t)
+	got := ts.sent.take()[aliceID]
+	if len(got) != 1 || !strings.Contains(got[0], `"Crash in &lt;b&gt;"`) || !strings.Contains(got[0], strings.Repeat("a", 299)+"&amp;...") {
+		t.Errorf("status notification = %q", got)
+	}
+}
+
+func TestLongCommentNotification(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.createCard(t, aliceID, "issue", "Crash", "")
+	// 300 characters of two bytes each; the message quotes the first 200
+	content := strings.Repeat("ошибка при", 30)
+	ts.createComment(t, bobID, id, content, false)
+	ts.deliver(t)
+	got := ts.sent.take()[aliceID]
+	if len(got) != 1 || !utf8.ValidString(got[0]) || !strings.Contains(got[0], ": "+string([]rune(content)[:200])+"...\n") {
+		t.Errorf("long comment notification = %q", got)
+	}
+}
+
+func TestNotificationRetries(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.createCard(t, aliceID, "issue", "Crash", "")
+
+	ts.sent.setFailing(true)
+	ts.createComment(t, bobID, id, "first", false)
+	ts.createComment(t, bobID, id, "second", false)
+	// Retries are due at once with no backoff configured, until the attempts run out
+	ts.deliver(t)
+
+	expect(t, ts.request(t, "GET", "/api/admin/notifications", anonymous, nil), 401)
+	expect(t, ts.request(t, "GET", "/api/admin/notifications", aliceID, nil), 403)
+	expect(t, ts.request(t, "GET", "/api/admin/notifications?state=sent", adminID, nil), 400)
+	if pending := ts.listNotifications(t, "?state=pending"); len(pending) != 0 {
+		t.Errorf("pending = %+v", pending)
+	}
+	dead := ts.listNotifications(t, "")
+	if len(dead) != 2 {
+		t.Fatalf("dead letters = %+v", dead)
+	}
+	for _, n := range dead {
+		if n.UserID != aliceID || n.Event != eventComment || n.Attempts != 3 || n.LastError != "telegram is down" || n.CardTitle != "Crash" {
+			t.Errorf("dead letter = %+v", n)
+		}
+	}
+
+	ts.sent.setFailing(false)
+	retry := fmt.Sprintf("/api/admin/notifications/%d/retry", dead[0].ID)
+	expect(t, ts.request(t, "POST", retry, aliceID, nil), 403)
+	expect(t, ts.request(t, "POST", "/api/admin/notifications/999/retry", adminID, nil), 404)
+	expect(t, ts.request(t, "POST", retry, adminID, nil), 200)
+	// Only dead letters can be retried
+	expect(t, ts.request(t, "POST", retry, adminID, nil), 404)
+	ts.deliver(t)
+	if got := ts.sent.take()[aliceID]; len(got) != 1 || !strings.Contains(got[0], "second") {
+		t.Errorf("retried = %q", got)
+	}
+
+	ts.sent.setFailing(true)
+	ts.createComment(t, bobID, id, "third", false)
+	ts.deliver(t)
+	ts.sent.setFailing(false)
+	expect(t, ts.request(t, "POST", "/api/admin/notifications/retry", aliceID, nil), 403)
+	r := ts.request(t, "POST", "/api/admin/notifications/retry", adminID, nil)
+	expect(t, r, 200)
+	var body struct {
+		Retried int `json:"retried"`
+	}
+	r.decode(t, &body)
+	if body.Retried != 2 {
+		t.Errorf("retried = %d", body.Retried)
+	}
+	ts.deliver(t)
+	if got := ts.sent.take()[aliceID]; len(got) != 2 {
+		t.Errorf("retried = %q", got)
+	}
+	if dead := ts.listNotifications(t, ""); len(dead) != 0 {
+		t.Errorf("dead letters after retry = %+v", dead)
+	}
+}
+
+func TestNotifyBackoff(t *testing.T) {
+	ts := newTestServer(t)
+	ts.cfg.NotifyRetryBase = 30 * time.Second
+	ts.cfg.NotifyRetryMax = 5 * time.Minute
+	for attempts, want := range map[int]time.Duration{
+		1: 30 * time.Second,
+		2: time.Minute,
+		4: 4 * time.Minute,
+		5: 5 * time.Minute,
+		9: 5 * time.Minute,
+	} {
+		if got := ts.h.notifyBackoff(attempts); got != want {
+			t.Errorf("notifyBackoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestRunNotifier(t *testing.T) {
+	ts := newTestServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ts.h.RunNotifier(ctx)
+		close(done)
+	}()
+
+	// New notifications wake the notifier long before its next poll
+	id := ts.createCard(t, aliceID, "issue", "Crash", "")
+	ts.createComment(t, bobID, id, "hello", false)
+	eventually(t, func() bool {
+		ts.sent.mu.Lock()
+		defer ts.sent.mu.Unlock()
+		return len(ts.sent.messages[aliceID]) == 1
+	})
+
+	cancel()
+	<-done
+}
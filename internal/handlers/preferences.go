@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"bugtracker/internal/models"
+)
+
+// Notification preferences say which events a user hears about on which channel and
+// when. They are applied when a notification is delivered, so a change also affects
+// notifications already queued: muted ones are dropped, and ones due during the user's
+// quiet hours wait until the quiet hours end.
+
+// Notification channels
+const channelTelegram = "telegram"
+
+var notificationChannels = []string{channelTelegram}
+
+// notificationEvents are the events users can mute
+var notificationEvents = []string{eventComment, eventStatus, eventMention, eventAssignment, eventMilestone}
+
+const defaultTimezone = "UTC"
+
+// notificationPreferences returns the user's preferences, or the defaults (everything
+// at any time) if they never changed them
+func (h *Handler) notificationPreferences(ctx context.Context, userID int64) (*models.NotificationPreferences, error) {
+	prefs, err := h.repo.GetNotificationPreferences(ctx, userID)
+	if err != nil || prefs != nil {
+		return prefs, err
+	}
+	return &models.NotificationPreferences{UserID: userID, Timezone: defaultTimezone}, nil
+}
+
+func mutedKey(event, channel string) string {
+	return event + ":" + channel
+}
+
+// isMuted tells whether the user turned off an event on a channel
+func isMuted(prefs *models.NotificationPreferences, event, channel string) bool {
+	key := mutedKey(event, channel)
+	for _, muted := range prefs.Muted {
+		if muted == key {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock parses a time of day as HH:MM into minutes after midnight
+func parseClock(s string) (int, bool) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// quietHoursEnd tells whether now is in the user's quiet hours and, if so, when they end.
+// Quiet hours may span midnight, e.g. 22:00 to 08:00.
+func quietHoursEnd(prefs *models.NotificationPreferences, now time.Time) (time.Time, bool) {
+	start, ok := parseClock(prefs.QuietStart)
+	if !ok {
+		return time.Time{}, false
+	}
+	end, ok := parseClock(prefs.QuietEnd)
+	if !ok || start == end {
+		return time.Time{}, false
+	}
+	loc, err := time.LoadLocation(prefs.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	if start < end && (minute < start || minute >= end) || start > end && minute < start && minute >= end {
+		return time.Time{}, false
+	}
+
+	year, month, day := local.Date()
+	until := time.Date(year, month, day, end/60, end%60, 0, 0, loc)
+	if !until.After(now) {
+		until = time.Date(year, month, day+1, end/60, end%60, 0, 0, loc)
+	}
+	return until, true
+}
+
+// preferencesJSON shows every event on every channel as on or off
+func preferencesJSON(prefs *models.NotificationPreferences) fiber.Map {
+	events := fiber.Map{}
+	for _, event := range notificationEvents {
+		channels := fiber.Map{}
+		for _, channel := range notificationChannels {
+			channels[channel] = !isMuted(prefs, event, channel)
+		}
+		events[event] = channels
+	}
+	return fiber.Map{
+		"timezone":    prefs.Timezone,
+		"quiet_start": prefs.QuietStart,
+		"quiet_end":   prefs.QuietEnd,
+		"events":      events,
+		"channels":    notificationChannels,
+	}
+}
+
+// GetMyNotificationPreferences returns the current user's notification preferences
+func (h *Handler) GetMyNotificationPreferences(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login required"})
+	}
+
+	prefs, err := h.notificationPreferences(c.UserContext(), user.ID)
+	if err != nil {
+		return h.dbError(c, err, "Error loading notification preferences")
+	}
+	return c.JSON(preferencesJSON(prefs))
+}
+
+// preferencesInput changes the fields that are set. Events maps event to channel to
+// whether to send it; events and channels left out keep their setting.
+type preferencesInput struct {
+	Timezone   *string                    `json:"timezone"`
+	QuietStart *string                    `json:"quiet_start"`
+	QuietEnd   *string                    `json:"quiet_end"`
+	Events     map[string]map[string]bool `json:"events"`
+}
+
+// APIUpdateNotificationPreferences changes the current user's notification preferences
+func (h *Handler) APIUpdateNotificationPreferences(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login required"})
+	}
+
+	var input preferencesInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	prefs, err := h.notificationPreferences(c.UserContext(), user.ID)
+	if err != nil {
+		return h.dbError(c, err, "Error loading notification preferences")
+	}
+
+	if input.Timezone != nil {
+		if _, err := time.LoadLocation(*input.Timezone); err != nil || *input.Timezone == "" || *input.Timezone == "Local" {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid timezone"})
+		}
+		prefs.Timezone = *input.Timezone
+	}
+	if input.QuietStart != nil {
+		prefs.QuietStart = *input.QuietStart
+	}
+	if input.QuietEnd != nil {
+		prefs.QuietEnd = *input.QuietEnd
+	}
+	if prefs.QuietStart != "" || prefs.QuietEnd != "" {
+		start, okStart := parseClock(prefs.QuietStart)
+		end, okEnd := parseClock(prefs.QuietEnd)
+		if !okStart || !okEnd || start == end {
+			return c.Status(400).JSON(fiber.Map{"error": "Quiet hours need a different start and end as HH:MM"})
+		}
+		prefs.QuietStart = fmt.Sprintf("%02d:%02d", start/60, start%60)
+		prefs.QuietEnd = fmt.Sprintf("%02d:%02d", end/60, end%60)
+	}
+
+	muted := make(map[string]bool)
+	for _, key := range prefs.Muted {
+		muted[key] = true
+	}
+	for event, channels := range input.Events {
+		if !slices.Contains(notificationEvents, event) {
+			return c.Status(400).JSON(fiber.Map{"error": "Unknown notification event: " + event})
+		}
+		for channel, on := range channels {
+			if !slices.Contains(notificationChannels, channel) {
+				return c.Status(400).JSON(fiber.Map{"error": "Unknown notification channel: " + channel})
+			}
+			muted[mutedKey(event, channel)] = !on
+		}
+	}
+	// Keep the muted pairs in a stable order
+	prefs.Muted = nil
+	for _, event := range notificationEvents {
+		for _, channel := range notificationChannels {
+			if muted[mutedKey(event, channel)] {
+				prefs.Muted = append(prefs.Muted, mutedKey(event, channel))
+			}
+		}
+	}
+
+	if err := h.repo.SaveNotificationPreferences(c.UserContext(), prefs); err != nil {
+		return h.dbError(c, err, "Error saving notification preferences")
+	}
+	return c.JSON(preferencesJSON(prefs))
+}
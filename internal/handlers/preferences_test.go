@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"bugtracker/internal/models"
+)
+
+type preferencesResponse struct {
+	Timezone   string                     `json:"timezone"`
+	QuietStart string                     `json:"quiet_start"`
+	QuietEnd   string                     `json:"quiet_end"`
+	Events     map[string]map[string]bool `json:"events"`
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	ts := newTestServer(t)
+	expect(t, ts.request(t, "GET", "/api/me/notifications", anonymous, nil), 401)
+	expect(t, ts.request(t, "PATCH", "/api/me/notifications", anonymous, fiber.Map{}), 401)
+
+	r := ts.request(t, "GET", "/api/me/notifications", aliceID, nil)
+	expect(t, r, 200)
+	var prefs preferencesResponse
+	r.decode(t, &prefs)
+	if prefs.Timezone != "UTC" || prefs.QuietStart != "" || len(prefs.Events) != len(notificationEvents) || !prefs.Events[eventMilestone][channelTelegram] {
+		t.Errorf("defaults = %+v", prefs)
+	}
+
+	for _, input := range []fiber.Map{
+		{"timezone": "Mars/Olympus"},
+		{"timezone": ""},
+		{"quiet_start": "22:00"},
+		{"quiet_start": "25:00", "quiet_end": "08:00"},
+		{"quiet_start": "08:00", "quiet_end": "08:00"},
+		{"events": fiber.Map{"likes": fiber.Map{"telegram": false}}},
+		{"events": fiber.Map{"comment": fiber.Map{"email": false}}},
+	} {
+		r := ts.request(t, "PATCH", "/api/me/notifications", aliceID, input)
+		if r.status != 400 {
+			t.Errorf("%v: status = %d", input, r.status)
+		}
+	}
+
+	r = ts.request(t, "PATCH", "/api/me/notifications", aliceID, fiber.Map{
+		"timezone":    "Europe/Moscow",
+		"quiet_start": "22:00",
+		"quiet_end":   "8:00",
+		"events":      fiber.Map{"comment": fiber.Map{"telegram": false}, "status": fiber.Map{"telegram": false}},
+	})
+	expect(t, r, 200)
+	// Fields left out keep their values
+	r = ts.request(t, "PATCH", "/api/me/notifications", aliceID, fiber.Map{"events": fiber.Map{"status": fiber.Map{"telegram": true}}})
+	expect(t, r, 200)
+
+	r = ts.request(t, "GET", "/api/me/notifications", aliceID, nil)
+	expect(t, r, 200)
+	prefs = preferencesResponse{}
+	r.decode(t, &prefs)
+	if prefs.Timezone != "Europe/Moscow" || prefs.QuietStart != "22:00" || prefs.QuietEnd != "08:00" ||
+		prefs.Events[eventComment][channelTelegram] || !prefs.Events[eventStatus][channelTelegram] || !prefs.Events[eventMention][channelTelegram] {
+		t.Errorf("preferences = %+v", prefs)
+	}
+
+	// Clearing the quiet hours
+	r = ts.request(t, "PATCH", "/api/me/notifications", aliceID, fiber.Map{"quiet_start": "", "quiet_end": ""})
+	expect(t, r, 200)
+	prefs = preferencesResponse{}
+	r.decode(t, &prefs)
+	if prefs.QuietStart != "" || prefs.QuietEnd != "" || prefs.Timezone != "Europe/Moscow" {
+		t.Errorf("preferences = %+v", prefs)
+	}
+}
+
+func TestMutedNotifications(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.createCard(t, aliceID, "issue", "Crash", "")
+	expect(t, ts.request(t, "PATCH", "/api/me/notifications", aliceID, fiber.Map{
+		"events": fiber.Map{"comment": fiber.Map{"telegram": false}},
+	}), 200)
+
+	ts.createComment(t, bobID, id, "hello", false)
+	expect(t, ts.request(t, "PATCH", fmt.Sprintf("/api/cards/%d/status", id), adminID, fiber.Map{"status": "fixed"}), 200)
+	ts.deliver(t)
+	got := ts.sent.take()[aliceID]
+	if len(got) != 1 || !strings.Contains(got[0], "Новый статус") {
+		t.Errorf("sent = %q", got)
+	}
+	// Muted notifications are dropped, not kept for later
+	if pending := ts.listNotifications(t, "?state=pending"); len(pending) != 0 {
+		t.Errorf("pending = %+v", pending)
+	}
+}
+
+func TestQuietHoursDeferNotifications(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.createCard(t, aliceID, "issue", "Crash", "")
+	now := time.Now().UTC()
+	expect(t, ts.request(t, "PATCH", "/api/me/notifications", aliceID, fiber.Map{
+		"quiet_start": now.Add(-time.Hour).Format("15:04"),
+		"quiet_end":   now.Add(2 * time.Hour).Format("15:04"),
+	}), 200)
+
+	ts.createComment(t, bobID, id, "hello", false)
+	ts.deliver(t)
+	if sent := ts.sent.take(); len(sent) != 0 {
+		t.Errorf("sent during quiet hours: %v", sent)
+	}
+	pending := ts.listNotifications(t, "?state=pending")
+	if len(pending) != 1 || pending[0].Attempts != 0 || pending[0].NextAttemptAt.Before(now.Add(time.Hour)) {
+		t.Fatalf("pending = %+v", pending)
+	}
+
+	// Once the quiet hours are over the notification goes out
+	expect(t, ts.request(t, "PATCH", "/api/me/notifications", aliceID, fiber.Map{"quiet_start": "", "quiet_end": ""}), 200)
+	if err := ts.store.DeferNotification(context.Background(), pending[0].ID, now); err != nil {
+		t.Fatal(err)
+	}
+	ts.deliver(t)
+	if got := ts.sent.take()[aliceID]; len(got) != 1 {
+		t.Errorf("sent after quiet hours = %q", got)
+	}
+}
+
+func TestQuietHoursEnd(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skip(err)
+	}
+	prefs := &models.NotificationPreferences{Timezone: "Europe/Moscow", QuietStart: "22:00", QuietEnd: "08:00"}
+	at := func(day, hour, minute int) time.Time { return time.Date(2026, 3, day, hour, minute, 0, 0, moscow) }
+
+	for _, tc := range []struct {
+		now   time.Time
+		until time.Time // zero when not quiet
+	}{
+		{at(10, 21, 59), time.Time{}},
+		{at(10, 22, 0), at(11, 8, 0)},
+		{at(11, 3, 30), at(11, 8, 0)},
+		{at(11, 8, 0), time.Time{}},
+		// The same instant seen from UTC
+		{at(11, 3, 30).UTC(), at(11, 8, 0)},
+	} {
+		until, quiet := quietHoursEnd(prefs, tc.now)
+		if quiet != !tc.until.IsZero() || !until.Equal(tc.until) {
+			t.Errorf("quietHoursEnd(%s) = %s, %v; want %s", tc.now, until, quiet, tc.until)
+		}
+	}
+
+	// Daytime quiet hours
+	prefs.QuietStart, prefs.QuietEnd = "13:00", "14:00"
+	if until, quiet := quietHoursEnd(prefs, at(10, 13, 15)); !quiet || !until.Equal(at(10, 14, 0)) {
+		t.Errorf("quietHoursEnd in daytime quiet hours = %s, %v", until, quiet)
+	}
+	if _, quiet := quietHoursEnd(prefs, at(10, 23, 0)); quiet {
+		t.Error("quiet outside daytime quiet hours")
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"bugtracker/internal/models"
+)
+
+// attachCardReactions fills Reactions on cards for the given viewer (0 for anonymous)
+func (h *Handler) attachCardReactions(ctx context.Context, cards []*models.Card, userID int64) error {
+	if len(cards) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(cards))
+	for i, card := range cards {
+		ids[i] = card.ID
+	}
+	reactions, err := h.repo.GetCardReactions(ctx, ids, userID)
+	if err != nil {
+		return err
+	}
+	for _, card := range cards {
+		card.Reactions = reactions[card.ID]
+	}
+	return nil
+}
+
+// attachCommentReactions fills Reactions on comments for the given viewer (0 for anonymous)
+func (h *Handler) attachCommentReactions(ctx context.Context, comments []*models.Comment, userID int64) error {
+	if len(comments) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+	reactions, err := h.repo.GetCommentReactions(ctx, ids, userID)
+	if err != nil {
+		return err
+	}
+	for _, comment := range comments {
+		comment.Reactions = reactions[comment.ID]
+	}
+	return nil
+}
+
+// reactionEmoji reads the emoji from the JSON body (POST) or the query string (DELETE)
+func reactionEmoji(c *fiber.Ctx) string {
+	if emoji := c.Query("emoji"); emoji != "" {
+		return emoji
+	}
+	var input struct {
+		Emoji string `json:"emoji"`
+	}
+	_ = c.BodyParser(&input)
+	return input.Emoji
+}
+
+// APIReactToCard adds or removes (DELETE) the current user's reaction on a card
+func (h *Handler) APIReactToCard(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login required"})
+	}
+
+	cardID, _ := strconv.ParseInt(c.Params("id"), 10, 64)
+	emoji := reactionEmoji(c)
+	if !h.cfg.IsAllowedReaction(emoji) {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid reaction"})
+	}
+
+	card, err := h.repo.GetCard(c.UserContext(), cardID, false)
+	if err != nil {
+		return h.dbError(c, err, "Error saving reaction")
+	}
+	if card == nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Card not found"})
+	}
+
+	if c.Method() == fiber.MethodDelete {
+		err = h.repo.RemoveCardReaction(c.UserContext(), cardID, user.ID, emoji)
+	} else {
+		err = h.repo.AddCardReaction(c.UserContext(), cardID, user.ID, emoji)
+	}
+	if err != nil {
+		return h.dbError(c, err, "Error saving reaction")
+	}
+
+	reactions, err := h.repo.GetCardReactions(c.UserContext(), []int64{cardID}, user.ID)
+	if err != nil {
+		return h.dbError(c, err, "Error loading reactions")
+	}
+
+	return c.JSON(fiber.Map{"reactions": reactions[cardID]})
+}
+
+// APIReactToComment adds or removes (DELETE) the current user's reaction on a comment
+func (h *Handler) APIReactToComment(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login required"})
+	}
+
+	commentID, _ := strconv.ParseInt(c.Params("id"), 10, 64)
+	emoji := reactionEmoji(c)
+	if !h.cfg.IsAllowedReaction(emoji) {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid reaction"})
+	}
+
+	comment, err := h.repo.GetComment(c.UserContext(), commentID)
+	if err != nil {
+		return h.dbError(c, err, "Error saving reaction")
+	}
+	if comment == nil || (comment.IsInternal && !h.cfg.IsAdmin(user.ID)) {
+		return c.Status(404).JSON(fiber.Map{"error": "Comment not found"})
+	}
+
+	if c.Method() == fiber.MethodDelete {
+		err = h.repo.RemoveCommentReaction(c.UserContext(), commentID, user.ID, emoji)
+	} else {
+		err = h.repo.AddCommentReaction(c.UserContext(), commentID, user.ID, emoji)
+	}
+	if err != nil {
+		return h.dbError(c, err, "Error saving reaction")
+	}
+
+	reactions, err := h.repo.GetCommentReactions(c.UserContext(), []int64{commentID}, user.ID)
+	if err != nil {
+		return h.dbError(c, err, "Error loading reactions")
+	}
+
+	return c.JSON(fiber.Map{"reactions": reactions[commentID]})
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"bugtracker/internal/models"
+)
+
+func reactionsOf(t *testing.T, r response) string {
+	t.Helper()
+	expect(t, r, 200)
+	var body struct {
+		Reactions []models.Reaction `json:"reactions"`
+	}
+	r.decode(t, &body)
+	return fmt.Sprint(body.Reactions)
+}
+
+func TestCardReactions(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.createCard(t, aliceID, "issue", "Crash", "")
+	path := fmt.Sprintf("/api/cards/%d/reactions", id)
+
+	expect(t, ts.request(t, "POST", path, anonymous, fiber.Map{"emoji": "👍"}), 401)
+	expect(t, ts.request(t, "POST", path, bobID, fiber.Map{"emoji": "💩"}), 400)
+	expect(t, ts.request(t, "POST", "/api/cards/999/reactions", bobID, fiber.Map{"emoji": "👍"}), 404)
+
+	reactionsOf(t, ts.request(t, "POST", path, aliceID, fiber.Map{"emoji": "🎉"}))
+	reactionsOf(t, ts.request(t, "POST", path, bobID, fiber.Map{"emoji": "👍"}))
+	// Reacting twice with the same emoji counts once
+	reactionsOf(t, ts.request(t, "POST", path, bobID, fiber.Map{"emoji": "🎉"}))
+	got := reactionsOf(t, ts.request(t, "POST", path, bobID, fiber.Map{"emoji": "🎉"}))
+	if want := "[{🎉 2 true} {👍 1 true}]"; got != want {
+		t.Errorf("reactions = %s, want %s", got, want)
+	}
+
+	got = reactionsOf(t, ts.request(t, "DELETE", path+"?emoji=👍", bobID, nil))
+	if want := "[{🎉 2 true}]"; got != want {
+		t.Errorf("after removing = %s, want %s", got, want)
+	}
+
+	r := ts.request(t, "GET", fmt.Sprintf("/api/cards/%d", id), aliceID, nil)
+	var body struct {
+		Card models.Card `json:"card"`
+	}
+	r.decode(t, &body)
+	if fmt.Sprint(body.Card.Reactions) != "[{🎉 2 true}]" {
+		t.Errorf("card reactions = %v", body.Card.Reactions)
+	}
+}
+
+func TestCommentReactions(t *testing.T) {
+	ts := newTestServer(t)
+	id := ts.createCard(t, aliceID, "issue", "Crash", "")
+	commentID := ts.createComment(t, bobID, id, "Same", false)
+	noteID := ts.createComment(t, adminID, id, "Internal", true)
+	path := fmt.Sprintf("/api/comments/%d/reactions", commentID)
+
+	expect(t, ts.request(t, "POST", path, anonymous, fiber.Map{"emoji": "👍"}), 401)
+	expect(t, ts.request(t, "POST", path, aliceID, fiber.Map{"emoji": "💩"}), 400)
+	expect(t, ts.request(t, "POST", fmt.Sprintf("/api/comments/%d/reactions", noteID), aliceID, fiber.Map{"emoji": "👍"}), 404)
+	reactionsOf(t, ts.request(t, "POST", fmt.Sprintf("/api/comments/%d/reactions", noteID), adminID, fiber.Map{"emoji": "👍"}))
+
+	if got := reactionsOf(t, ts.request(t, "POST", path, aliceID, fiber.Map{"emoji": "👍"})); got != "[{👍 1 true}]" {
+		t.Errorf("reactions = %s", got)
+	}
+	if got := reactionsOf(t, ts.request(t, "DELETE", path, aliceID, fiber.Map{"emoji": "👍"})); got != "[]" {
+		t.Errorf("after removing = %s", got)
+	}
+
+	// Reactions go with a deleted comment
+	reactionsOf(t, ts.request(t, "POST", path, aliceID, fiber.Map{"emoji": "🎉"}))
+	expect(t, ts.request(t, "DELETE", fmt.Sprintf("/api/comments/%d", commentID), adminID, nil), 200)
+	reactions, _ := ts.store.GetCommentReactions(t.Context(), []int64{commentID}, aliceID)
+	if len(reactions) != 0 {
+		t.Errorf("reactions of a deleted comment = %v", reactions)
+	}
+}
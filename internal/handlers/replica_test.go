@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"bugtracker/internal/config"
+	"bugtracker/internal/repository"
+	"bugtracker/internal/repository/memory"
+)
+
+func TestReadYourWrites(t *testing.T) {
+	cfg := &config.Config{ReplicaStickiness: 10 * time.Second}
+	h := New(memory.New(), cfg)
+
+	var primary bool
+	app := fiber.New()
+	app.Use(h.ReadYourWritesMiddleware)
+	app.All("/", func(c *fiber.Ctx) error {
+		primary = repository.IsPrimary(c.UserContext())
+		if c.Query("fail") != "" {
+			return c.SendStatus(400)
+		}
+		return c.SendStatus(200)
+	})
+
+	send := func(method, path, cookie string) (setCookie string) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, nil)
+		if cookie != "" {
+			req.Header.Set("Cookie", cookie)
+		}
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.Header.Get("Set-Cookie")
+	}
+
+	// Without a replica everything is read from the primary anyway
+	if set := send("POST", "/", ""); set != "" || primary {
+		t.Errorf("no replica: Set-Cookie %q, primary %v", set, primary)
+	}
+
+	cfg.DatabaseReplicaURL = "postgres://replica"
+	if set := send("GET", "/", ""); set != "" || primary {
+		t.Errorf("GET: Set-Cookie %q, primary %v", set, primary)
+	}
+	set := send("POST", "/", "")
+	if !primary || !strings.Contains(set, "read_primary=1") || !strings.Contains(set, "max-age=10") {
+		t.Errorf("POST: Set-Cookie %q, primary %v", set, primary)
+	}
+	if send("GET", "/", "read_primary=1"); !primary {
+		t.Error("GET after a write: not reading from the primary")
+	}
+	// A failed write changed nothing, so it doesn't pin the client
+	if set := send("DELETE", "/?fail=1", ""); set != "" || !primary {
+		t.Errorf("failed DELETE: Set-Cookie %q, primary %v", set, primary)
+	}
+}
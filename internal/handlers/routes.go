@@ -0,0 +1,48 @@
+package handlers
+
+import "github.com/gofiber/fiber/v2"
+
+// Routes registers the JSON API on api (mounted at /api)
+func (h *Handler) Routes(api fiber.Router) {
+	api.Get("/config", h.GetConfig)
+	api.Get("/auth/me", h.GetMe)
+	api.Post("/auth/telegram", h.APITelegramAuth)
+	api.Post("/auth/logout", h.APILogout)
+	api.Get("/me/mentions", h.GetMyMentions)
+	api.Get("/me/notifications", h.GetMyNotificationPreferences)
+	api.Patch("/me/notifications", h.APIUpdateNotificationPreferences)
+	api.Get("/me/vote-budget", h.GetMyVoteBudget)
+	api.Get("/search", h.Search)
+	api.Get("/views", h.GetViews)
+	api.Get("/views/counts", h.GetViewCounts)
+	api.Get("/views/shared/:token", h.GetSharedView)
+	api.Get("/views/:id", h.GetView)
+	api.Get("/views/:id/count", h.GetViewCount)
+	api.Post("/views", h.APICreateView)
+	api.Patch("/views/:id", h.APIUpdateView)
+	api.Delete("/views/:id", h.APIDeleteView)
+	api.Get("/cards", h.GetCards)
+	api.Get("/cards/similar", h.GetSimilarCards)
+	api.Get("/cards/:id", h.GetCard)
+	api.Post("/cards", h.APICreateCard)
+	api.Delete("/cards/:id", h.APIDeleteCard)
+	api.Patch("/cards/:id/status", h.APIUpdateCardStatus)
+	api.Post("/cards/:id/vote", h.APIVote)
+	api.Get("/cards/:id/votes", h.GetCardVotes)
+	api.Get("/cards/:id/comments", h.GetComments)
+	api.Post("/cards/:id/comments", h.APICreateComment)
+	api.Post("/cards/:id/reactions", h.APIReactToCard)
+	api.Delete("/cards/:id/reactions", h.APIReactToCard)
+	api.Delete("/comments/:id", h.APIDeleteComment)
+	api.Post("/comments/:id/reactions", h.APIReactToComment)
+	api.Delete("/comments/:id/reactions", h.APIReactToComment)
+	api.Patch("/comments/:id/official", h.APISetCommentOfficial)
+	api.Post("/upload", h.APIUploadFile)
+	api.Post("/upload/image", h.APIUploadImage) // Legacy endpoint for ImgBB
+	api.Get("/admin/activity", h.GetStaffActivity)
+	api.Get("/admin/votes", h.GetReviewVotes)
+	api.Patch("/admin/cards/:id/votes/:userId", h.APIReviewVote)
+	api.Get("/admin/notifications", h.GetNotifications)
+	api.Post("/admin/notifications/retry", h.APIRetryNotifications)
+	api.Post("/admin/notifications/:id/retry", h.APIRetryNotification)
+}
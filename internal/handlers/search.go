@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"bugtracker/internal/models"
+)
+
+const searchCommentsPerCard = 3
+
+// Search looks for q in card titles, descriptions and comments. Results are grouped
+// by card; each carries its best matching comments with excerpts and deep links.
+func (h *Handler) Search(c *fiber.Ctx) error {
+	q := strings.TrimSpace(c.Query("q"))
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+
+	if q == "" {
+		return c.JSON(fiber.Map{"results": []*models.SearchResult{}, "total": 0, "has_more": false})
+	}
+
+	results, total, err := h.repo.Search(c.UserContext(), q, limit, offset, searchCommentsPerCard, h.isStaff(c))
+	if err != nil {
+		return h.dbError(c, err, "Error searching")
+	}
+	if results == nil {
+		results = []*models.SearchResult{}
+	}
+	for _, res := range results {
+		res.URL = cardPath(res.CardID, 0)
+		for _, m := range res.Comments {
+			m.URL = cardPath(res.CardID, m.ID)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"results":  results,
+		"total":    total,
+		"has_more": offset+len(results) < total,
+	})
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"bugtracker/internal/models"
+)
+
+func TestSearch(t *testing.T) {
+	ts := newTestServer(t)
+	crash := ts.createCard(t, aliceID, "issue", "Crash on start", "The app crashes <sometimes>")
+	dark := ts.createCard(t, bobID, "suggestion", "Dark theme", "")
+	commentID := ts.createComment(t, aliceID, dark, "It crashes when switching themes", false)
+	ts.createComment(t, adminID, crash, "Crash reproduced", true)
+
+	search := func(userID int64, q string) (results []*models.SearchResult, total int) {
+		t.Helper()
+		r := ts.request(t, "GET", "/api/search?q="+url.QueryEscape(q), userID, nil)
+		expect(t, r, 200)
+		var body struct {
+			Results []*models.SearchResult `json:"results"`
+			Total   int                    `json:"total"`
+		}
+		r.decode(t, &body)
+		return body.Results, body.Total
+	}
+
+	results, total := search(anonymous, "crash")
+	if total != 2 || len(results) != 2 {
+		t.Fatalf("got %d of %d results, want 2", len(results), total)
+	}
+	byCard := map[int64]*models.SearchResult{}
+	for _, res := range results {
+		byCard[res.CardID] = res
+	}
+
+	res := byCard[crash]
+	if res == nil || !res.CardMatch || res.CommentMatches != 0 || res.URL != fmt.Sprintf("/c/%d", crash) {
+		t.Errorf("card match = %+v", res)
+	} else if res.Snippet != "The app <mark>crashes</mark> &lt;sometimes&gt;" {
+		t.Errorf("snippet = %q", res.Snippet)
+	}
+
+	res = byCard[dark]
+	if res == nil || res.CardMatch || res.CommentMatches != 1 || len(res.Comments) != 1 {
+		t.Fatalf("comment match = %+v", res)
+	}
+	if m := res.Comments[0]; m.ID != commentID || m.URL != fmt.Sprintf("/c/%d#comment-%d", dark, commentID) || m.Author.Username != "alice" {
+		t.Errorf("comment = %+v", m)
+	}
+
+	// Internal notes are only searched for staff
+	results, _ = search(adminID, "reproduced")
+	if len(results) != 1 || results[0].Comments[0].IsInternal != true {
+		t.Errorf("staff results = %+v", results)
+	}
+	if results, total = search(anonymous, "reproduced"); total != 0 || len(results) != 0 {
+		t.Errorf("anonymous results = %+v", results)
+	}
+
+	if results, _ = search(anonymous, "crash -theme"); len(results) != 1 || results[0].CardID != crash {
+		t.Errorf("exclusion results = %+v", results)
+	}
+	if results, total = search(anonymous, "  "); total != 0 || results == nil {
+		t.Errorf("blank query = %+v, %d", results, total)
+	}
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"bugtracker/internal/models"
+	"bugtracker/internal/repository"
+	"bugtracker/internal/search"
+)
+
+const maxViewNameLength = 100
+
+type viewInput struct {
+	Name     string   `json:"name"`
+	Sort     string   `json:"sort"`
+	Type     string   `json:"type"`
+	Status   string   `json:"status"`
+	Query    string   `json:"query"`
+	Tags     []string `json:"tags"`
+	IsPublic *bool    `json:"is_public"`
+}
+
+// validate normalizes the input and returns a user facing error message
+func (in *viewInput) validate() string {
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		return "Name is required"
+	}
+	if len([]rune(in.Name)) > maxViewNameLength {
+		return "Name is too long"
+	}
+	if in.Sort == "" {
+		in.Sort = "rate"
+	}
+	if !repository.ValidSort(in.Sort) {
+		return "Invalid sort"
+	}
+	if in.Type != "" && !models.CardTypes[in.Type] {
+		return "Invalid type"
+	}
+	if in.Status != "" && !models.CardStatuses[in.Status] {
+		return "Invalid status"
+	}
+	in.Tags = cleanTags(in.Tags)
+	if _, _, err := cardFilter(in.Type, in.Status, in.Query, in.Tags); err != nil {
+		var syntaxErr *search.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return "Invalid query: " + syntaxErr.Message
+		}
+		return "Invalid query"
+	}
+	return ""
+}
+
+// canSeeView reports whether the view is listed for the user. Views that are
+// neither public nor owned are still reachable through their share link.
+func (h *Handler) canSeeView(c *fiber.Ctx, v *models.SavedView) bool {
+	if v.IsPublic || h.isStaff(c) {
+		return true
+	}
+	user, ok := c.Locals("user").(*models.User)
+	return ok && user != nil && user.ID == v.UserID
+}
+
+// GetViews lists the published views and the current user's own views
+func (h *Handler) GetViews(c *fiber.Ctx) error {
+	var userID int64
+	if user, ok := c.Locals("user").(*models.User); ok && user != nil {
+		userID = user.ID
+	}
+
+	views, err := h.repo.ListSavedViews(c.UserContext(), userID)
+	if err != nil {
+		return h.dbError(c, err, "Error loading views")
+	}
+	if views == nil {
+		views = []*models.SavedView{}
+	}
+	return c.JSON(fiber.Map{"views": views})
+}
+
+// GetView returns a view by id
+func (h *Handler) GetView(c *fiber.Ctx) error {
+	id, _ := strconv.ParseInt(c.Params("id"), 10, 64)
+	view, err := h.repo.GetSavedView(c.UserContext(), id)
+	if err != nil || view == nil || !h.canSeeView(c, view) {
+		return c.Status(404).JSON(fiber.Map{"error": "View not found"})
+	}
+	return c.JSON(view)
+}
+
+// GetSharedView resolves a share link to its view
+func (h *Handler) GetSharedView(c *fiber.Ctx) error {
+	view, err := h.repo.GetSavedViewByToken(c.UserContext(), c.Params("token"))
+	if err != nil || view == nil {
+		return c.Status(404).JSON(fiber.Map{"error": "View not found"})
+	}
+	return c.JSON(view)
+}
+
+// GetViewCounts returns the number of matching cards for every listed view, for badges
+func (h *Handler) GetViewCounts(c *fiber.Ctx) error {
+	var userID int64
+	if user, ok := c.Locals("user").(*models.User); ok && user != nil {
+		userID = user.ID
+	}
+
+	views, err := h.repo.ListSavedViews(c.UserContext(), userID)
+	if err != nil {
+		return h.dbError(c, err, "Error loading views")
+	}
+
+	// Every view is counted in the same query; views that can't match anything count 0
+	counts := make(map[string]int, len(views))
+	var filters []repository.CardFilter
+	var ids []string
+	for _, v := range views {
+		id := strconv.FormatInt(v.ID, 10)
+		counts[id] = 0
+		if filter, ok, err := cardFilter(v.Type, v.Status, v.Query, v.Tags); err == nil && ok {
+			filters = append(filters, filter)
+			ids = append(ids, id)
+		}
+	}
+	n, err := h.repo.CountCardsBatch(c.UserContext(), filters)
+	if err != nil {
+		return h.dbError(c, err, "Error counting cards")
+	}
+	for i, id := range ids {
+		counts[id] = n[i]
+	}
+	return c.JSON(fiber.Map{"counts": counts})
+}
+
+// GetViewCount returns the number of cards matching a single view
+func (h *Handler) GetViewCount(c *fiber.Ctx) error {
+	id, _ := strconv.ParseInt(c.Params("id"), 10, 64)
+	view, err := h.repo.GetSavedView(c.UserContext(), id)
+	if err != nil || view == nil || !h.canSeeView(c, view) {
+		return c.Status(404).JSON(fiber.Map{"error": "View not found"})
+	}
+
+	n, err := h.viewCount(c.UserContext(), view)
+	if err != nil {
+		return h.dbError(c, err, "Error counting cards")
+	}
+	return c.JSON(fiber.Map{"id": view.ID, "count": n})
+}
+
+func (h *Handler) viewCount(ctx context.Context, v *models.SavedView) (int, error) {
+	filter, ok, err := cardFilter(v.Type, v.Status, v.Query, v.Tags)
+	if err != nil || !ok {
+		// A view saved before the search syntax changed matches nothing
+		return 0, nil
+	}
+	return h.repo.CountCards(ctx, filter)
+}
+
+// APICreateView saves the given filters as a view of the current user
+func (h *Handler) APICreateView(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login required"})
+	}
+
+	var input viewInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if msg := input.validate(); msg != "" {
+		return c.Status(400).JSON(fiber.Map{"error": msg})
+	}
+	if input.IsPublic != nil && *input.IsPublic && !h.cfg.IsAdmin(user.ID) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin access required"})
+	}
+
+	view := &models.SavedView{
+		UserID:     user.ID,
+		Name:       input.Name,
+		Sort:       input.Sort,
+		Type:       input.Type,
+		Status:     input.Status,
+		Query:      input.Query,
+		Tags:       input.Tags,
+		ShareToken: uuid.New().String(),
+		IsPublic:   input.IsPublic != nil && *input.IsPublic,
+		CreatedAt:  time.Now(),
+	}
+	if err := h.repo.CreateSavedView(c.UserContext(), view); err != nil {
+		return h.dbError(c, err, "Error saving view")
+	}
+	return c.Status(201).JSON(view)
+}
+
+// APIUpdateView changes a view. Owners edit their views; admins publish or unpublish any view.
+func (h *Handler) APIUpdateView(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login required"})
+	}
+
+	id, _ := strconv.ParseInt(c.Params("id"), 10, 64)
+	view, err := h.repo.GetSavedView(c.UserContext(), id)
+	if err != nil || view == nil || !h.canSeeView(c, view) {
+		return c.Status(404).JSON(fiber.Map{"error": "View not found"})
+	}
+
+	input := viewInput{
+		Name:   view.Name,
+		Sort:   view.Sort,
+		Type:   view.Type,
+		Status: view.Status,
+		Query:  view.Query,
+		Tags:   view.Tags,
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	admin := h.cfg.IsAdmin(user.ID)
+	if input.IsPublic != nil && *input.IsPublic != view.IsPublic && !admin {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin access required"})
+	}
+	changed := input.Name != view.Name || input.Sort != view.Sort || input.Type != view.Type ||
+		input.Status != view.Status || input.Query != view.Query || strings.Join(input.Tags, ",") != strings.Join(view.Tags, ",")
+	if changed && view.UserID != user.ID {
+		return c.Status(403).JSON(fiber.Map{"error": "Only the owner can edit this view"})
+	}
+	if msg := input.validate(); msg != "" {
+		return c.Status(400).JSON(fiber.Map{"error": msg})
+	}
+
+	view.Name = input.Name
+	view.Sort = input.Sort
+	view.Type = input.Type
+	view.Status = input.Status
+	view.Query = input.Query
+	view.Tags = input.Tags
+	if input.IsPublic != nil {
+		view.IsPublic = *input.IsPublic
+	}
+	if err := h.repo.UpdateSavedView(c.UserContext(), view); err != nil {
+		return h.dbError(c, err, "Error saving view")
+	}
+	return c.JSON(view)
+}
+
+// APIDeleteView removes a view; allowed for its owner and admins
+func (h *Handler) APIDeleteView(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login required"})
+	}
+
+	id, _ := strconv.ParseInt(c.Params("id"), 10, 64)
+	view, err := h.repo.GetSavedView(c.UserContext(), id)
+	if err != nil || view == nil {
+		return c.Status(404).JSON(fiber.Map{"error": "View not found"})
+	}
+	if view.UserID != user.ID && !h.cfg.IsAdmin(user.ID) {
+		return c.Status(403).JSON(fiber.Map{"error": "Only the owner can delete this view"})
+	}
+
+	if err := h.repo.DeleteSavedView(c.UserContext(), id); err != nil {
+		return h.dbError(c, err, "Error deleting view")
+	}
+	return c.JSON(fiber.Map{"ok": true})
+}
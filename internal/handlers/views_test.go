@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"bugtracker/internal/models"
+)
+
+func (ts *testServer) createView(t *testing.T, userID int64, view fiber.Map) *models.SavedView {
+	t.Helper()
+	r := ts.request(t, "POST", "/api/views", userID, view)
+	expect(t, r, 201)
+	var v models.SavedView
+	r.decode(t, &v)
+	return &v
+}
+
+func TestCreateView(t *testing.T) {
+	ts := newTestServer(t)
+
+	expect(t, ts.request(t, "POST", "/api/views", anonymous, fiber.Map{"name": "Mine"}), 401)
+	expect(t, ts.request(t, "POST", "/api/views", aliceID, fiber.Map{"name": " "}), 400)
+	expect(t, ts.request(t, "POST", "/api/views", aliceID, fiber.Map{"name": "Bad", "sort": "random"}), 400)
+	expect(t, ts.request(t, "POST", "/api/views", aliceID, fiber.Map{"name": "Bad", "query": `"open`}), 400)
+	expect(t, ts.request(t, "POST", "/api/views", aliceID, fiber.Map{"name": "Bad", "type": "bug"}), 400)
+	expect(t, ts.request(t, "POST", "/api/views", aliceID, fiber.Map{"name": "Bad", "status": "pending"}), 400)
+	expect(t, ts.request(t, "POST", "/api/views", aliceID, fiber.Map{"name": "Public", "is_public": true}), 403)
+
+	v := ts.createView(t, aliceID, fiber.Map{"name": " iOS bugs ", "type": "issue", "tags": []string{"ios", " iOS", ""}})
+	if v.Name != "iOS bugs" || v.Sort != "rate" || v.ShareToken == "" || fmt.Sprint(v.Tags) != "[ios]" {
+		t.Errorf("view = %+v", v)
+	}
+
+	// Without tags the view stores an empty list; the column is NOT NULL
+	v = ts.createView(t, aliceID, fiber.Map{"name": "No tags"})
+	if len(v.Tags) != 0 {
+		t.Errorf("tags = %v", v.Tags)
+	}
+	expect(t, ts.request(t, "PATCH", fmt.Sprintf("/api/views/%d", v.ID), aliceID, fiber.Map{"tags": nil}), 200)
+}
+
+func TestListViews(t *testing.T) {
+	ts := newTestServer(t)
+	public := ts.createView(t, adminID, fiber.Map{"name": "Open issues", "status": "open", "is_public": true})
+	own := ts.createView(t, aliceID, fiber.Map{"name": "Mine", "query": "author:alice"})
+	other := ts.createView(t, bobID, fiber.Map{"name": "Bob's"})
+
+	names := func(userID int64) string {
+		r := ts.request(t, "GET", "/api/views", userID, nil)
+		expect(t, r, 200)
+		var body struct {
+			Views []*models.SavedView `json:"views"`
+		}
+		r.decode(t, &body)
+		var out []string
+		for _, v := range body.Views {
+			out = append(out, v.Name)
+		}
+		return fmt.Sprint(out)
+	}
+	if got := names(anonymous); got != "[Open issues]" {
+		t.Errorf("anonymous views = %s", got)
+	}
+	if got := names(aliceID); got != "[Open issues Mine]" {
+		t.Errorf("alice's views = %s", got)
+	}
+
+	expect(t, ts.request(t, "GET", fmt.Sprintf("/api/views/%d", own.ID), aliceID, nil), 200)
+	expect(t, ts.request(t, "GET", fmt.Sprintf("/api/views/%d", own.ID), bobID, nil), 404)
+	expect(t, ts.request(t, "GET", fmt.Sprintf("/api/views/%d", public.ID), anonymous, nil), 200)
+
+	// Share links open any view
+	r := ts.request(t, "GET", "/api/views/shared/"+other.ShareToken, aliceID, nil)
+	expect(t, r, 200)
+	var shared models.SavedView
+	r.decode(t, &shared)
+	if shared.ID != other.ID {
+		t.Errorf("shared view = %+v", shared)
+	}
+	expect(t, ts.request(t, "GET", "/api/views/shared/nope", aliceID, nil), 404)
+}
+
+func TestViewCounts(t *testing.T) {
+	ts := newTestServer(t)
+	ts.createCard(t, aliceID, "issue", "Crash", "")
+	ts.createCard(t, bobID, "issue", "Slow", "")
+	ts.createCard(t, aliceID, "suggestion", "Dark theme", "")
+	issues := ts.createView(t, aliceID, fiber.Map{"name": "Issues", "type": "issue"})
+	mine := ts.createView(t, aliceID, fiber.Map{"name": "Mine", "query": "author:alice"})
+	none := ts.createView(t, aliceID, fiber.Map{"name": "None", "type": "issue", "query": "type:suggestion"})
+
+	r := ts.request(t, "GET", "/api/views/counts", aliceID, nil)
+	expect(t, r, 200)
+	var body struct {
+		Counts map[string]int `json:"counts"`
+	}
+	r.decode(t, &body)
+	want := map[string]int{fmt.Sprint(issues.ID): 2, fmt.Sprint(mine.ID): 2, fmt.Sprint(none.ID): 0}
+	if fmt.Sprint(body.Counts) != fmt.Sprint(want) {
+		t.Errorf("counts = %v, want %v", body.Counts, want)
+	}
+
+	r = ts.request(t, "GET", fmt.Sprintf("/api/views/%d/count", issues.ID), aliceID, nil)
+	expect(t, r, 200)
+	var count struct {
+		Count int `json:"count"`
+	}
+	r.decode(t, &count)
+	if count.Count != 2 {
+		t.Errorf("count = %d", count.Count)
+	}
+	expect(t, ts.request(t, "GET", fmt.Sprintf("/api/views/%d/count", issues.ID), bobID, nil), 404)
+}
+
+func TestUpdateView(t *testing.T) {
+	ts := newTestServer(t)
+	v := ts.createView(t, aliceID, fiber.Map{"name": "Mine"})
+	path := fmt.Sprintf("/api/views/%d", v.ID)
+
+	expect(t, ts.request(t, "PATCH", path, anonymous, fiber.Map{"name": "X"}), 401)
+	expect(t, ts.request(t, "PATCH", path, bobID, fiber.Map{"name": "X"}), 404)
+	expect(t, ts.request(t, "PATCH", path, aliceID, fiber.Map{"is_public": true}), 403)
+	expect(t, ts.request(t, "PATCH", path, aliceID, fiber.Map{"sort": "random"}), 400)
+
+	r := ts.request(t, "PATCH", path, aliceID, fiber.Map{"name": "Renamed", "sort": "time"})
+	expect(t, r, 200)
+
+	// Admins publish views but can't edit other people's filters
+	expect(t, ts.request(t, "PATCH", path, adminID, fiber.Map{"name": "Hijacked"}), 403)
+	r = ts.request(t, "PATCH", path, adminID, fiber.Map{"is_public": true})
+	expect(t, r, 200)
+	var updated models.SavedView
+	r.decode(t, &updated)
+	if updated.Name != "Renamed" || updated.Sort != "time" || !updated.IsPublic {
+		t.Errorf("view = %+v", updated)
+	}
+	expect(t, ts.request(t, "GET", path, bobID, nil), 200)
+}
+
+func TestDeleteView(t *testing.T) {
+	ts := newTestServer(t)
+	v := ts.createView(t, aliceID, fiber.Map{"name": "Mine"})
+	path := fmt.Sprintf("/api/views/%d", v.ID)
+
+	expect(t, ts.request(t, "DELETE", path, anonymous, nil), 401)
+	expect(t, ts.request(t, "DELETE", path, bobID, nil), 403)
+	expect(t, ts.request(t, "DELETE", path, aliceID, nil), 200)
+	expect(t, ts.request(t, "GET", path, aliceID, nil), 404)
+	expect(t, ts.request(t, "DELETE", path, aliceID, nil), 404)
+}
@@ -12,6 +12,12 @@ type User struct {
 	IsAdmin   bool      `json:"is_admin"`
 }
 
+// Card types and statuses
+var (
+	CardTypes    = map[string]bool{"issue": true, "suggestion": true}
+	CardStatuses = map[string]bool{"open": true, "closed": true, "fixed": true, "fix_coming": true}
+)
+
 type Card struct {
 	ID          int64     `json:"id"`
 	UserID      int64     `json:"user_id"`
@@ -25,9 +31,78 @@ type Card struct {
 	Dislikes    int       `json:"dislikes"`
 	CreatedAt   time.Time `json:"created_at"`
 	// Joined fields
-	Author       *User `json:"author,omitempty"`
-	CommentCount int   `json:"comment_count"`
-	UserVote     int   `json:"user_vote,omitempty"` // -1, 0, 1
+	Author              *User      `json:"author,omitempty"`
+	CommentCount        int        `json:"comment_count"`
+	UserVote            int        `json:"user_vote,omitempty"` // -1, 0, 1
+	HasOfficialResponse bool       `json:"has_official_response"`
+	Reactions           []Reaction `json:"reactions,omitempty"`
+	// Search results only: HTML-escaped text with matches wrapped in <mark>
+	TitleHighlight string `json:"title_highlight,omitempty"`
+	Snippet        string `json:"snippet,omitempty"`
+}
+
+// SimilarCard is an existing card that may duplicate the one being written
+type SimilarCard struct {
+	ID         int64   `json:"id"`
+	Title      string  `json:"title"`
+	Type       string  `json:"type"`
+	Status     string  `json:"status"`
+	Rating     int     `json:"rating"`
+	Likes      int     `json:"likes"`
+	Dislikes   int     `json:"dislikes"`
+	Similarity float64 `json:"similarity"` // trigram similarity of the titles, 0..1
+	Rank       float64 `json:"rank"`       // full-text rank against title and description
+}
+
+// SearchResult is a card matching a search by its title and description or through its comments
+type SearchResult struct {
+	CardID         int64           `json:"card_id"`
+	Title          string          `json:"title"` // HTML-escaped, matches wrapped in <mark>
+	Type           string          `json:"type"`
+	Status         string          `json:"status"`
+	Snippet        string          `json:"snippet,omitempty"` // description excerpt when the card itself matched
+	CardMatch      bool            `json:"card_match"`
+	CommentMatches int             `json:"comment_matches"` // number of matching comments
+	Comments       []*CommentMatch `json:"comments"`        // best matching comments
+	URL            string          `json:"url"`
+}
+
+// CommentMatch is a comment matching a search
+type CommentMatch struct {
+	ID         int64     `json:"id"`
+	Excerpt    string    `json:"excerpt"` // HTML-escaped, matches wrapped in <mark>
+	IsInternal bool      `json:"is_internal,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	Author     *User     `json:"author,omitempty"`
+	URL        string    `json:"url"` // card link with the comment anchor
+}
+
+// FacetCount is the number of cards having a value in a facet of the card list
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// CardFacets counts cards per status, type and tag
+type CardFacets struct {
+	Statuses []FacetCount `json:"status"`
+	Types    []FacetCount `json:"type"`
+	Tags     []FacetCount `json:"tag"`
+}
+
+// SavedView is a named combination of card list filters
+type SavedView struct {
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"user_id"`
+	Name       string    `json:"name"`
+	Sort       string    `json:"sort"`
+	Type       string    `json:"type,omitempty"`
+	Status     string    `json:"status,omitempty"`
+	Query      string    `json:"query,omitempty"`
+	Tags       []string  `json:"tags,omitempty"`
+	ShareToken string    `json:"share_token"`
+	IsPublic   bool      `json:"is_public"` // published by an admin to everyone
+	CreatedAt  time.Time `json:"created_at"`
 }
 
 type Tag struct {
@@ -41,21 +116,97 @@ type CardTag struct {
 }
 
 type Vote struct {
-	UserID int64 `json:"user_id"`
-	CardID int64 `json:"card_id"`
-	Value  int   `json:"value"` // 1 or -1
+	UserID     int64     `json:"user_id"`
+	CardID     int64     `json:"card_id"`
+	Value      int       `json:"value"`            // 1 or -1
+	Status     string    `json:"status,omitempty"` // valid, flagged, voided
+	FlagReason string    `json:"flag_reason,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	// Joined fields
+	User      *User  `json:"user,omitempty"`
+	CardTitle string `json:"card_title,omitempty"`
+}
+
+// VoteResult is the state of a card's votes right after a user toggled their vote
+type VoteResult struct {
+	CardID   int64 `json:"card_id"`
+	Value    int   `json:"value"` // the user's vote after the toggle: -1, 0, 1
+	Rating   int   `json:"rating"`
+	Likes    int   `json:"likes"`
+	Dislikes int   `json:"dislikes"`
+	// Suggestion votes left in the user's budget, when budgets are enabled
+	RemainingVotes *int `json:"remaining_votes,omitempty"`
 }
 
 type Comment struct {
+	ID         int64      `json:"id"`
+	CardID     int64      `json:"card_id"`
+	UserID     int64      `json:"user_id"`
+	Content    string     `json:"content"`
+	Images     []string   `json:"images,omitempty"`
+	IsInternal bool       `json:"is_internal,omitempty"` // visible to staff only
+	IsOfficial bool       `json:"is_official,omitempty"` // marked by staff as the official response
+	CreatedAt  time.Time  `json:"created_at"`
+	Author     *User      `json:"author,omitempty"`
+	Reactions  []Reaction `json:"reactions,omitempty"`
+}
+
+// Reaction is the aggregated count of one emoji on a card or comment
+type Reaction struct {
+	Emoji   string `json:"emoji"`
+	Count   int    `json:"count"`
+	Reacted bool   `json:"reacted,omitempty"` // the current user left this reaction
+}
+
+type Mention struct {
 	ID        int64     `json:"id"`
-	CardID    int64     `json:"card_id"`
 	UserID    int64     `json:"user_id"`
+	CardID    int64     `json:"card_id"`
+	CardTitle string    `json:"card_title"`
+	CommentID int64     `json:"comment_id,omitempty"`
 	Content   string    `json:"content"`
-	Images    []string  `json:"images,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	Author    *User     `json:"author,omitempty"`
 }
 
+// Notification is a Telegram message to a user about a change, kept in the outbox
+// until it is delivered. The message is rendered at delivery from the event and the
+// card, comment and user it refers to.
+type Notification struct {
+	ID         int64  `json:"id"`
+	UserID     int64  `json:"user_id"` // recipient
+	Event      string `json:"event"`   // comment, mention, status
+	CardID     int64  `json:"card_id"`
+	CommentID  int64  `json:"comment_id,omitempty"`
+	ActorID    int64  `json:"actor_id,omitempty"`    // who made the change
+	CardStatus string `json:"card_status,omitempty"` // the new status, for status events
+	// Delivery
+	State         string    `json:"state"` // pending, dead
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LastError     string    `json:"last_error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	// Joined fields
+	CardTitle string `json:"card_title,omitempty"`
+}
+
+// NotificationPreferences are a user's choice of notifications. Every event is sent on
+// every channel unless muted.
+type NotificationPreferences struct {
+	UserID     int64    `json:"-"`
+	Timezone   string   `json:"timezone"`    // IANA name, e.g. Europe/Moscow
+	QuietStart string   `json:"quiet_start"` // HH:MM in Timezone; empty for no quiet hours
+	QuietEnd   string   `json:"quiet_end"`
+	Muted      []string `json:"-"` // "event:channel" pairs turned off
+}
+
+// StaffActivity aggregates comments written by a staff member, internal notes included
+type StaffActivity struct {
+	User             *User `json:"user"`
+	Comments         int   `json:"comments"`
+	InternalComments int   `json:"internal_comments"`
+}
+
 type TelegramAuthData struct {
 	ID        int64  `json:"id"`
 	FirstName string `json:"first_name"`
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+)
+
+// The card list benchmarks need a database, e.g. one filled by cmd/seed:
+//
+//	DATABASE_URL=postgres://... go test -run '^$' -bench ListCards ./internal/repository
+//
+// "counters" runs the current list query, which reads the vote and comment counters
+// stored on cards; "subqueries" runs the same query counting votes and comments per
+// card, as the list did before the counters were added.
+
+func benchDB(b *testing.B) *sql.DB {
+	b.Helper()
+	url := os.Getenv("DATABASE_URL")
+	if url == "" {
+		b.Skip("DATABASE_URL is not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.Close() })
+	if err := db.Ping(); err != nil {
+		b.Fatal(err)
+	}
+	return db
+}
+
+// withCountSubqueries replaces the stored counters in a list query with the subqueries
+// they replaced
+func withCountSubqueries(b *testing.B, query string) string {
+	b.Helper()
+	for _, r := range []struct{ counter, subquery string }{
+		{"c.comment_count + CASE WHEN $2 THEN c.internal_comment_count ELSE 0 END",
+			"(SELECT COUNT(*) FROM comments WHERE card_id = c.id AND (NOT is_internal OR $2))"},
+		{"c.likes, c.dislikes",
+			"(SELECT COUNT(*) FROM votes WHERE card_id = c.id AND value = 1 AND status = 'valid')," +
+				" (SELECT COUNT(*) FROM votes WHERE card_id = c.id AND value = -1 AND status = 'valid')"},
+	} {
+		if !strings.Contains(query, r.counter) {
+			b.Fatalf("list query no longer reads %s", r.counter)
+		}
+		query = strings.Replace(query, r.counter, r.subquery, 1)
+	}
+	return query
+}
+
+func BenchmarkListCards(b *testing.B) {
+	db := benchDB(b)
+	cases := []struct {
+		name   string
+		filter CardFilter
+		sort   string
+	}{
+		{"rate", CardFilter{}, "rate"},
+		{"time", CardFilter{}, "time"},
+		{"hot", CardFilter{}, "hot"},
+		{"most_discussed", CardFilter{}, "most_discussed"},
+		{"open_issues", CardFilter{Types: []string{"issue"}, Statuses: []string{"open"}}, "rate"},
+		{"search", CardFilter{Query: "crash"}, "relevance"},
+	}
+	for _, tc := range cases {
+		query, args := listCardsSQL(tc.filter, tc.sort, CardPage{Limit: 20}, 0, false)
+		for _, variant := range []struct{ name, query string }{
+			{"counters", query},
+			{"subqueries", withCountSubqueries(b, query)},
+		} {
+			b.Run(tc.name+"/"+variant.name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					rows, err := db.Query(variant.query, args...)
+					if err != nil {
+						b.Fatal(err)
+					}
+					for rows.Next() {
+					}
+					if err := rows.Close(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Card list queries are built here from a CardFilter, a sort mode and a CardPage, so that
+// the list, its count and estimate and the facets share one rendering of every filter.
+// The search and similar card queries take their card conditions from the same cardConds.
+// The builders only produce SQL and parameters; the Repository methods execute them.
+
+// CardFilter selects cards in ListCards. Empty fields don't filter;
+// values within Types, Statuses and Authors are alternatives, all Tags are required.
+type CardFilter struct {
+	Types    []string
+	Statuses []string
+	Tags     []string
+	Authors  []string // usernames, case-insensitive
+	Query    string   // full-text search in web search syntax
+	// Rating (likes - dislikes) bounds, inclusive
+	MinRating *int
+	MaxRating *int
+	// Creation time bounds: CreatedFrom inclusive, CreatedTo exclusive
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+}
+
+// Facets of CardFilter that can be left out when counting per facet value
+const (
+	facetStatus = "status"
+	facetType   = "type"
+)
+
+// ErrInvalidCursor is returned for a cursor that is malformed or made for another sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// CardCursor is a keyset position in the card list: the sort key and id of the last card seen
+type CardCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"` // sort key as PostgreSQL text
+	ID   int64  `json:"i"`
+}
+
+// Encode returns the cursor as an opaque URL-safe token
+func (c *CardCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCardCursor parses a token made by Encode
+func DecodeCardCursor(token string) (*CardCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := &CardCursor{}
+	if err := json.Unmarshal(data, c); err != nil || c.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// TotalMode selects how ListCards counts the matching cards
+type TotalMode int
+
+const (
+	TotalExact    TotalMode = iota // COUNT(*)
+	TotalEstimate                  // planner row estimate, cheap on large tables
+	TotalNone                      // not counted, total is -1
+)
+
+// CardPage selects a page of the card list, by keyset position (After) or by Offset
+type CardPage struct {
+	Limit  int
+	Offset int         // ignored when After is set
+	After  *CardCursor // continue after this card
+	Total  TotalMode
+}
+
+// sqlArgs collects query parameters and hands out their placeholders
+type sqlArgs []interface{}
+
+func (a *sqlArgs) add(v interface{}) string {
+	*a = append(*a, v)
+	return "$" + itoa(len(*a))
+}
+
+// tsQuery builds a full-text query in both search configurations from the user's
+// search text in parameter $n (web search syntax: words, "phrases", OR, -exclusions)
+func tsQuery(n int) string {
+	return "(websearch_to_tsquery('russian', $" + itoa(n) + ") || websearch_to_tsquery('english', $" + itoa(n) + "))"
+}
+
+// Highlighted matches are wrapped in control characters by ts_headline and
+// turned into <mark> tags after the rest of the text has been HTML-escaped
+const (
+	headlineTitleOptions   = `E'StartSel=\x02, StopSel=\x03, HighlightAll=true'`
+	headlineSnippetOptions = `E'StartSel=\x02, StopSel=\x03, MaxFragments=2, MaxWords=35, MinWords=15, FragmentDelimiter=" … "'`
+)
+
+func highlight(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, "\x02", "<mark>")
+	return strings.ReplaceAll(s, "\x03", "</mark>")
+}
+
+// sortColumns maps the sorts other than rate and relevance to the column cards are
+// sorted by and its type
+var sortColumns = map[string]struct{ expr, typ string }{
+	"time":           {"c.created_at", "timestamptz"},
+	"hot":            {"c.hot_score", "float8"},
+	"best":           {"c.best_score", "float8"},
+	"controversial":  {"c.controversy_score", "float8"},
+	"most_discussed": {"c.comment_count", "int"},
+}
+
+// ValidSort reports whether cards can be listed in the given sort
+func ValidSort(sort string) bool {
+	_, ok := sortColumns[sort]
+	return ok || sort == "rate" || sort == "relevance"
+}
+
+// sortKey returns the SQL expression cards are sorted by, descending and then by id,
+// and its type. The relevance sort needs the placeholder number of the search text;
+// without a search it falls back to rating.
+func sortKey(sort string, search int) (expr, typ string) {
+	if col, ok := sortColumns[sort]; ok {
+		return col.expr, col.typ
+	}
+	if sort == "relevance" && search > 0 {
+		return "ts_rank_cd(c.search_vector, " + tsQuery(search) + ")::float8", "float8"
+	}
+	return "c.rating", "int"
+}
+
+// cardConds renders the filter as conditions on cards c joined with users u, adding
+// their parameters to args. Facets named in skip are left out. search is the
+// placeholder number of the full-text query, 0 without one.
+func cardConds(filter CardFilter, args *sqlArgs, skip ...string) (conds []string, search int) {
+	skipped := func(facet string) bool {
+		for _, s := range skip {
+			if s == facet {
+				return true
+			}
+		}
+		return false
+	}
+
+	if filter.Query != "" {
+		args.add(filter.Query)
+		search = len(*args)
+		conds = append(conds, "c.search_vector @@ "+tsQuery(search))
+	}
+	if len(filter.Types) > 0 && !skipped(facetType) {
+		conds = append(conds, "c.type = ANY("+args.add(pq.Array(filter.Types))+")")
+	}
+	if len(filter.Statuses) > 0 && !skipped(facetStatus) {
+		conds = append(conds, "c.status = ANY("+args.add(pq.Array(filter.Statuses))+")")
+	}
+	for _, tag := range filter.Tags {
+		conds = append(conds, "EXISTS(SELECT 1 FROM card_tags ct JOIN tags t ON ct.tag_id = t.id WHERE ct.card_id = c.id AND LOWER(t.name) = LOWER("+args.add(tag)+"))")
+	}
+	if len(filter.Authors) > 0 {
+		authors := make([]string, len(filter.Authors))
+		for i, a := range filter.Authors {
+			authors[i] = strings.ToLower(a)
+		}
+		conds = append(conds, "LOWER(u.username) = ANY("+args.add(pq.Array(authors))+")")
+	}
+	if filter.MinRating != nil {
+		conds = append(conds, "c.rating >= "+args.add(*filter.MinRating))
+	}
+	if filter.MaxRating != nil {
+		conds = append(conds, "c.rating <= "+args.add(*filter.MaxRating))
+	}
+	if !filter.CreatedFrom.IsZero() {
+		conds = append(conds, "c.created_at >= "+args.add(filter.CreatedFrom))
+	}
+	if !filter.CreatedTo.IsZero() {
+		conds = append(conds, "c.created_at < "+args.add(filter.CreatedTo))
+	}
+	return conds, search
+}
+
+func where(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+const cardsFrom = " FROM cards c JOIN users u ON c.user_id = u.id"
+
+// listCardsSQL builds the card list query. It selects one row more than page.Limit
+// to tell whether there is a next page, and the sort key as text for the cursor.
+func listCardsSQL(filter CardFilter, sort string, page CardPage, userID int64, includeInternal bool) (string, []interface{}) {
+	var args sqlArgs
+	user := args.add(userID)
+	internal := args.add(includeInternal)
+	conds, search := cardConds(filter, &args)
+	keyExpr, keyType := sortKey(sort, search)
+
+	headlines := "'', ''"
+	if search > 0 {
+		headlines = "ts_headline('russian', c.title, " + tsQuery(search) + ", " + headlineTitleOptions + "), " +
+			"ts_headline('russian', COALESCE(c.description, ''), " + tsQuery(search) + ", " + headlineSnippetOptions + ")"
+	}
+
+	offset := page.Offset
+	if page.After != nil {
+		key, id := args.add(page.After.Key), args.add(page.After.ID)
+		conds = append(conds, "("+keyExpr+", c.id) < ("+key+"::"+keyType+", "+id+")")
+		offset = 0
+	}
+	limit, off := args.add(page.Limit+1), args.add(offset)
+
+	query := "SELECT c.id, c.user_id, c.title, COALESCE(c.description, ''), c.type, c.status, COALESCE(c.images, '{}'), c.rating, c.created_at," +
+		" u.id, u.first_name, COALESCE(u.last_name, ''), COALESCE(u.username, ''), COALESCE(u.photo_url, '')," +
+		" c.comment_count + CASE WHEN " + internal + " THEN c.internal_comment_count ELSE 0 END," +
+		" COALESCE((SELECT value FROM votes WHERE card_id = c.id AND user_id = " + user + "), 0)," +
+		" c.likes, c.dislikes," +
+		" EXISTS(SELECT 1 FROM comments WHERE card_id = c.id AND is_official)," +
+		" " + headlines + "," +
+		" (" + keyExpr + ")::text" +
+		cardsFrom + where(conds) +
+		" ORDER BY " + keyExpr + " DESC, c.id DESC" +
+		" LIMIT " + limit + " OFFSET " + off
+	return query, args
+}
+
+// countCardsSQL counts the cards matching the filter
+func countCardsSQL(filter CardFilter) (string, []interface{}) {
+	var args sqlArgs
+	conds, _ := cardConds(filter, &args)
+	return "SELECT COUNT(*)" + cardsFrom + where(conds), args
+}
+
+// countCardsBatchSQL counts the cards matching each filter in a single scan, returning
+// one column per filter
+func countCardsBatchSQL(filters []CardFilter) (string, []interface{}) {
+	var args sqlArgs
+	counts := make([]string, len(filters))
+	for i, filter := range filters {
+		conds, _ := cardConds(filter, &args)
+		counts[i] = "COUNT(*)"
+		if len(conds) > 0 {
+			counts[i] += " FILTER (WHERE " + strings.Join(conds, " AND ") + ")"
+		}
+	}
+	return "SELECT " + strings.Join(counts, ", ") + cardsFrom, args
+}
+
+// estimateCardsSQL asks the planner how many cards match the filter, without counting them
+func estimateCardsSQL(filter CardFilter) (string, []interface{}) {
+	var args sqlArgs
+	conds, _ := cardConds(filter, &args)
+	return "EXPLAIN (FORMAT JSON) SELECT 1" + cardsFrom + where(conds), args
+}
+
+// cardFacetsSQL counts matching cards per status, type and tag. Status and type values
+// are alternatives, so their counts ignore the filter's own statuses and types; tags are
+// all required, so tag counts keep every condition.
+func cardFacetsSQL(filter CardFilter) (string, []interface{}) {
+	var args sqlArgs
+	statusConds, _ := cardConds(filter, &args, facetStatus)
+	typeConds, _ := cardConds(filter, &args, facetType)
+	tagConds, _ := cardConds(filter, &args)
+
+	query := "SELECT 'status', c.status, COUNT(*)" + cardsFrom + where(statusConds) + " GROUP BY c.status" +
+		" UNION ALL" +
+		" SELECT 'type', c.type, COUNT(*)" + cardsFrom + where(typeConds) + " GROUP BY c.type" +
+		" UNION ALL" +
+		" SELECT 'tag', tg.name, COUNT(*)" + cardsFrom +
+		" JOIN card_tags ctg ON ctg.card_id = c.id JOIN tags tg ON tg.id = ctg.tag_id" + where(tagConds) + " GROUP BY tg.name" +
+		" ORDER BY 1, 3 DESC, 2"
+	return query, args
+}
+
+// searchSQL finds the cards matching the search text by their own text or by their
+// comments, best matches first, with highlighted titles and snippets
+func searchSQL(text string, limit, offset int, includeInternal bool) (string, []interface{}) {
+	var args sqlArgs
+	conds, search := cardConds(CardFilter{Query: text}, &args)
+	internal := args.add(includeInternal)
+
+	query := "WITH hits AS (" +
+		"SELECT c.id AS card_id, ts_rank_cd(c.search_vector, " + tsQuery(search) + ") AS rank, TRUE AS card_match" +
+		cardsFrom + where(conds) +
+		" UNION ALL" +
+		" SELECT cm.card_id, ts_rank_cd(cm.search_vector, " + tsQuery(search) + "), FALSE" +
+		" FROM comments cm WHERE cm.search_vector @@ " + tsQuery(search) + " AND (NOT cm.is_internal OR " + internal + ")" +
+		"), ranked AS (" +
+		"SELECT card_id, MAX(rank) AS rank, bool_or(card_match) AS card_match, COUNT(*) FILTER (WHERE NOT card_match) AS comment_matches" +
+		" FROM hits GROUP BY card_id" +
+		")" +
+		" SELECT c.id, c.type, c.status, r.card_match, r.comment_matches," +
+		" ts_headline('russian', c.title, " + tsQuery(search) + ", " + headlineTitleOptions + ")," +
+		" CASE WHEN r.card_match THEN ts_headline('russian', COALESCE(c.description, ''), " + tsQuery(search) + ", " + headlineSnippetOptions + ") ELSE '' END," +
+		" COUNT(*) OVER ()" +
+		" FROM ranked r JOIN cards c ON c.id = r.card_id" +
+		" ORDER BY r.rank DESC, c.id DESC" +
+		" LIMIT " + args.add(limit) + " OFFSET " + args.add(offset)
+	return query, args
+}
+
+// searchCommentsSQL selects up to perCard best comments matching the search text on each
+// of the cards, with excerpts
+func searchCommentsSQL(text string, cardIDs []int64, perCard int, includeInternal bool) (string, []interface{}) {
+	var args sqlArgs
+	args.add(text)
+	q := tsQuery(len(args))
+
+	query := "SELECT m.id, m.card_id, m.is_internal, m.created_at," +
+		" ts_headline('russian', m.content, " + q + ", " + headlineSnippetOptions + ")," +
+		" u.id, u.first_name, COALESCE(u.last_name, ''), COALESCE(u.username, ''), COALESCE(u.photo_url, '')" +
+		" FROM (" +
+		"SELECT cm.*, ROW_NUMBER() OVER (PARTITION BY cm.card_id ORDER BY ts_rank_cd(cm.search_vector, " + q + ") DESC, cm.id) AS n" +
+		" FROM comments cm" +
+		" WHERE cm.card_id = ANY(" + args.add(pq.Array(cardIDs)) + ") AND cm.search_vector @@ " + q +
+		" AND (NOT cm.is_internal OR " + args.add(includeInternal) + ")" +
+		") m" +
+		" JOIN users u ON m.user_id = u.id" +
+		" WHERE m.n <= " + args.add(perCard) +
+		" ORDER BY m.card_id, m.n"
+	return query, args
+}
+
+// openStatuses are the statuses of cards that are neither closed nor fixed
+var openStatuses = []string{"open", "fix_coming"}
+
+// similarCardsSQL finds open cards whose title resembles title by trigram similarity
+// (the % operator, with the threshold set in pg_trgm.similarity_threshold) or full-text
+// rank. Any of the title's words may match, so the AND-ed plain queries are OR-ed.
+func similarCardsSQL(title string, opts SimilarCardsOptions) (string, []interface{}) {
+	var args sqlArgs
+	t := args.add(title)
+	filter := CardFilter{Statuses: openStatuses}
+	if opts.Type != "" {
+		filter.Types = []string{opts.Type}
+	}
+	conds, _ := cardConds(filter, &args)
+	words := "(replace(plainto_tsquery('russian', " + t + ")::text, '&', '|')::tsquery" +
+		" || replace(plainto_tsquery('english', " + t + ")::text, '&', '|')::tsquery)"
+	conds = append(conds, "(c.title % "+t+" OR c.search_vector @@ "+words+")")
+
+	query := "SELECT * FROM (" +
+		"SELECT c.id, c.title, c.type, c.status, c.rating, c.likes, c.dislikes," +
+		" similarity(c.title, " + t + ") AS sim, ts_rank_cd(c.search_vector, " + words + ") AS rank" +
+		cardsFrom + where(conds) +
+		") matches" +
+		" WHERE sim >= " + args.add(opts.MinSimilarity) + " OR rank >= " + args.add(opts.MinRank) +
+		" ORDER BY sim + rank DESC, id DESC" +
+		" LIMIT " + args.add(opts.Limit)
+	return query, args
+}
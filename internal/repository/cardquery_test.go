@@ -0,0 +1,287 @@
+package repository
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func intPtr(n int) *int { return &n }
+
+const tsq = "(websearch_to_tsquery('russian', $1) || websearch_to_tsquery('english', $1))"
+
+func TestCardConds(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		filter     CardFilter
+		skip       []string
+		wantConds  []string
+		wantArgs   []interface{}
+		wantSearch int
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:      "types and statuses",
+			filter:    CardFilter{Types: []string{"issue"}, Statuses: []string{"open", "fixed"}},
+			wantConds: []string{"c.type = ANY($1)", "c.status = ANY($2)"},
+			wantArgs:  []interface{}{pq.Array([]string{"issue"}), pq.Array([]string{"open", "fixed"})},
+		},
+		{
+			name:       "full-text query comes first",
+			filter:     CardFilter{Query: "crash", Types: []string{"issue"}},
+			wantConds:  []string{"c.search_vector @@ " + tsq, "c.type = ANY($2)"},
+			wantArgs:   []interface{}{"crash", pq.Array([]string{"issue"})},
+			wantSearch: 1,
+		},
+		{
+			name:   "every tag is required",
+			filter: CardFilter{Tags: []string{"ui", "ios"}},
+			wantConds: []string{
+				"EXISTS(SELECT 1 FROM card_tags ct JOIN tags t ON ct.tag_id = t.id WHERE ct.card_id = c.id AND LOWER(t.name) = LOWER($1))",
+				"EXISTS(SELECT 1 FROM card_tags ct JOIN tags t ON ct.tag_id = t.id WHERE ct.card_id = c.id AND LOWER(t.name) = LOWER($2))",
+			},
+			wantArgs: []interface{}{"ui", "ios"},
+		},
+		{
+			name:      "authors are lowercased",
+			filter:    CardFilter{Authors: []string{"Alice", "bob"}},
+			wantConds: []string{"LOWER(u.username) = ANY($1)"},
+			wantArgs:  []interface{}{pq.Array([]string{"alice", "bob"})},
+		},
+		{
+			name:      "rating and creation bounds",
+			filter:    CardFilter{MinRating: intPtr(-2), MaxRating: intPtr(10), CreatedFrom: day, CreatedTo: day.AddDate(0, 0, 1)},
+			wantConds: []string{"c.rating >= $1", "c.rating <= $2", "c.created_at >= $3", "c.created_at < $4"},
+			wantArgs:  []interface{}{-2, 10, day, day.AddDate(0, 0, 1)},
+		},
+		{
+			name:      "skipped facets",
+			filter:    CardFilter{Types: []string{"issue"}, Statuses: []string{"open"}, MinRating: intPtr(1)},
+			skip:      []string{facetStatus, facetType},
+			wantConds: []string{"c.rating >= $1"},
+			wantArgs:  []interface{}{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args sqlArgs
+			conds, search := cardConds(tt.filter, &args, tt.skip...)
+			if !reflect.DeepEqual(conds, tt.wantConds) {
+				t.Errorf("conds = %q, want %q", conds, tt.wantConds)
+			}
+			if !reflect.DeepEqual([]interface{}(args), tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", args, tt.wantArgs)
+			}
+			if search != tt.wantSearch {
+				t.Errorf("search = %d, want %d", search, tt.wantSearch)
+			}
+		})
+	}
+}
+
+func TestListCardsSQL(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   CardFilter
+		sort     string
+		page     CardPage
+		contains []string
+		wantArgs []interface{}
+	}{
+		{
+			name:     "default sort with offset",
+			page:     CardPage{Limit: 20, Offset: 40},
+			contains: []string{"(c.rating)::text FROM cards c JOIN users u ON c.user_id = u.id ORDER BY c.rating DESC, c.id DESC LIMIT $3 OFFSET $4"},
+			wantArgs: []interface{}{int64(7), false, 21, 40},
+		},
+		{
+			name:   "filters follow user and internal flag",
+			filter: CardFilter{Statuses: []string{"open"}},
+			sort:   "time",
+			page:   CardPage{Limit: 10},
+			contains: []string{
+				"CASE WHEN $2 THEN c.internal_comment_count",
+				"user_id = $1), 0)",
+				" WHERE c.status = ANY($3) ORDER BY c.created_at DESC, c.id DESC LIMIT $4 OFFSET $5",
+			},
+			wantArgs: []interface{}{int64(7), false, pq.Array([]string{"open"}), 11, 0},
+		},
+		{
+			name:   "relevance sort and headlines use the search parameter",
+			filter: CardFilter{Query: "crash"},
+			sort:   "relevance",
+			page:   CardPage{Limit: 10},
+			contains: []string{
+				"ts_headline('russian', c.title, (websearch_to_tsquery('russian', $3)",
+				"ORDER BY ts_rank_cd(c.search_vector, (websearch_to_tsquery('russian', $3) || websearch_to_tsquery('english', $3)))::float8 DESC, c.id DESC",
+			},
+			wantArgs: []interface{}{int64(7), false, "crash", 11, 0},
+		},
+		{
+			name:     "relevance without search falls back to rating",
+			sort:     "relevance",
+			page:     CardPage{Limit: 10},
+			contains: []string{"'', '',", "ORDER BY c.rating DESC, c.id DESC"},
+			wantArgs: []interface{}{int64(7), false, 11, 0},
+		},
+		{
+			name:     "cursor replaces offset",
+			filter:   CardFilter{Types: []string{"issue"}},
+			sort:     "hot",
+			page:     CardPage{Limit: 10, Offset: 30, After: &CardCursor{Sort: "hot", Key: "1.5", ID: 42}},
+			contains: []string{" WHERE c.type = ANY($3) AND (c.hot_score, c.id) < ($4::float8, $5) ORDER BY c.hot_score DESC, c.id DESC LIMIT $6 OFFSET $7"},
+			wantArgs: []interface{}{int64(7), false, pq.Array([]string{"issue"}), "1.5", int64(42), 11, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := listCardsSQL(tt.filter, tt.sort, tt.page, 7, false)
+			for _, want := range tt.contains {
+				if !strings.Contains(query, want) {
+					t.Errorf("query does not contain %q:\n%s", want, query)
+				}
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestCountCardsSQL(t *testing.T) {
+	tests := []struct {
+		name      string
+		filter    CardFilter
+		wantQuery string
+		wantArgs  []interface{}
+	}{
+		{
+			name:      "empty",
+			wantQuery: "SELECT COUNT(*) FROM cards c JOIN users u ON c.user_id = u.id",
+		},
+		{
+			name:      "search and rating",
+			filter:    CardFilter{Query: "crash", MinRating: intPtr(3)},
+			wantQuery: "SELECT COUNT(*) FROM cards c JOIN users u ON c.user_id = u.id WHERE c.search_vector @@ " + tsq + " AND c.rating >= $2",
+			wantArgs:  []interface{}{"crash", 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := countCardsSQL(tt.filter)
+			if query != tt.wantQuery {
+				t.Errorf("query = %q, want %q", query, tt.wantQuery)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestCountCardsBatchSQL(t *testing.T) {
+	query, args := countCardsBatchSQL([]CardFilter{{}, {Statuses: []string{"open"}, MinRating: intPtr(3)}})
+
+	want := "SELECT COUNT(*), COUNT(*) FILTER (WHERE c.status = ANY($1) AND c.rating >= $2)" +
+		" FROM cards c JOIN users u ON c.user_id = u.id"
+	if query != want {
+		t.Errorf("query = %q, want %q", query, want)
+	}
+	if wantArgs := []interface{}{pq.Array([]string{"open"}), 3}; !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %#v, want %#v", args, wantArgs)
+	}
+}
+
+func TestCardFacetsSQL(t *testing.T) {
+	filter := CardFilter{Types: []string{"issue"}, Statuses: []string{"open"}}
+	query, args := cardFacetsSQL(filter)
+
+	want := "SELECT 'status', c.status, COUNT(*) FROM cards c JOIN users u ON c.user_id = u.id WHERE c.type = ANY($1) GROUP BY c.status" +
+		" UNION ALL SELECT 'type', c.type, COUNT(*) FROM cards c JOIN users u ON c.user_id = u.id WHERE c.status = ANY($2) GROUP BY c.type" +
+		" UNION ALL SELECT 'tag', tg.name, COUNT(*) FROM cards c JOIN users u ON c.user_id = u.id" +
+		" JOIN card_tags ctg ON ctg.card_id = c.id JOIN tags tg ON tg.id = ctg.tag_id WHERE c.type = ANY($3) AND c.status = ANY($4) GROUP BY tg.name" +
+		" ORDER BY 1, 3 DESC, 2"
+	if query != want {
+		t.Errorf("query = %q, want %q", query, want)
+	}
+	wantArgs := []interface{}{
+		pq.Array([]string{"issue"}), pq.Array([]string{"open"}),
+		pq.Array([]string{"issue"}), pq.Array([]string{"open"}),
+	}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %#v, want %#v", args, wantArgs)
+	}
+}
+
+func TestSearchSQL(t *testing.T) {
+	query, args := searchSQL("crash", 20, 40, true)
+	for _, want := range []string{
+		"TRUE AS card_match FROM cards c JOIN users u ON c.user_id = u.id WHERE c.search_vector @@ " + tsq + " UNION ALL",
+		"FROM comments cm WHERE cm.search_vector @@ " + tsq + " AND (NOT cm.is_internal OR $2)",
+		"ORDER BY r.rank DESC, c.id DESC LIMIT $3 OFFSET $4",
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query does not contain %q:\n%s", want, query)
+		}
+	}
+	if wantArgs := []interface{}{"crash", true, 20, 40}; !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %#v, want %#v", args, wantArgs)
+	}
+
+	query, args = searchCommentsSQL("crash", []int64{3, 5}, 2, false)
+	want := "WHERE cm.card_id = ANY($2) AND cm.search_vector @@ " + tsq + " AND (NOT cm.is_internal OR $3)) m JOIN users u ON m.user_id = u.id WHERE m.n <= $4"
+	if !strings.Contains(query, want) {
+		t.Errorf("query does not contain %q:\n%s", want, query)
+	}
+	if wantArgs := []interface{}{"crash", pq.Array([]int64{3, 5}), false, 2}; !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %#v, want %#v", args, wantArgs)
+	}
+}
+
+func TestSimilarCardsSQL(t *testing.T) {
+	opts := SimilarCardsOptions{MinSimilarity: 0.3, MinRank: 0.1, Limit: 5}
+	query, args := similarCardsSQL("App crash", opts)
+	want := " WHERE c.status = ANY($2) AND (c.title % $1 OR c.search_vector @@ "
+	if !strings.Contains(query, want) || !strings.HasSuffix(query, " matches WHERE sim >= $3 OR rank >= $4 ORDER BY sim + rank DESC, id DESC LIMIT $5") {
+		t.Errorf("query = %s", query)
+	}
+	if wantArgs := []interface{}{"App crash", pq.Array(openStatuses), 0.3, 0.1, 5}; !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %#v, want %#v", args, wantArgs)
+	}
+
+	opts.Type = "issue"
+	query, args = similarCardsSQL("App crash", opts)
+	if want := " WHERE c.type = ANY($2) AND c.status = ANY($3) AND "; !strings.Contains(query, want) {
+		t.Errorf("query does not contain %q:\n%s", want, query)
+	}
+	if len(args) != 6 || !reflect.DeepEqual(args[1], pq.Array([]string{"issue"})) {
+		t.Errorf("args = %#v", args)
+	}
+}
+
+func TestCardCursor(t *testing.T) {
+	c := &CardCursor{Sort: "time", Key: "2024-03-01 10:00:00.123456+00", ID: 42}
+	got, err := DecodeCardCursor(c.Encode())
+	if err != nil {
+		t.Fatalf("DecodeCardCursor: %v", err)
+	}
+	if *got != *c {
+		t.Errorf("round trip = %+v, want %+v", got, c)
+	}
+
+	for _, token := range []string{"", "not base64!", "e30"} {
+		if _, err := DecodeCardCursor(token); err != ErrInvalidCursor {
+			t.Errorf("DecodeCardCursor(%q) error = %v, want ErrInvalidCursor", token, err)
+		}
+	}
+}
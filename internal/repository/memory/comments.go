@@ -0,0 +1,251 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"bugtracker/internal/models"
+	"bugtracker/internal/repository"
+)
+
+// Comment operations
+
+// CreateComment creates a comment with the mentions in it and the notifications about it,
+// whose CommentID is set to the new comment's
+func (s *Store) CreateComment(ctx context.Context, c *models.Comment, mentioned []int64, notify []*models.Notification) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	card := s.cards[c.CardID]
+	if card == nil || s.users[c.UserID] == nil || !s.usersExist(mentioned) || !s.notificationsValid(notify, false) {
+		return ErrForeignKey
+	}
+	s.lastCommentID++
+	c.ID = s.lastCommentID
+
+	s.comments[c.ID] = &models.Comment{
+		ID:         c.ID,
+		CardID:     c.CardID,
+		UserID:     c.UserID,
+		Content:    c.Content,
+		Images:     append([]string{}, c.Images...),
+		IsInternal: c.IsInternal,
+		CreatedAt:  now(),
+	}
+	s.countComment(card, c.IsInternal, 1)
+
+	s.createMentions(c.CardID, c.ID, c.UserID, mentioned)
+	for _, n := range notify {
+		n.CommentID = c.ID
+	}
+	s.enqueueNotifications(notify)
+	return nil
+}
+
+// countComment adjusts the card's public or internal comment counter
+func (s *Store) countComment(c *card, internal bool, delta int) {
+	if internal {
+		c.internalCommentCount += delta
+	} else {
+		c.commentCount += delta
+	}
+	s.cardChanged(c)
+}
+
+// commentRow returns a comment with its author, nil if the author is missing
+func (s *Store) commentRow(c *models.Comment) *models.Comment {
+	author := s.author(c.UserID)
+	if author == nil {
+		return nil
+	}
+	row := *c
+	row.Images = append([]string{}, c.Images...)
+	row.Author = author
+	return &row
+}
+
+// ListComments returns a page of comments of a card in ascending order, the total number
+// of comments and whether more exist past the page in its direction.
+// Internal comments are skipped unless includeInternal is set.
+func (s *Store) ListComments(ctx context.Context, cardID int64, page repository.CommentPage, includeInternal bool) ([]*models.Comment, int, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var all []*models.Comment
+	for _, c := range s.comments {
+		if c.CardID == cardID && (!c.IsInternal || includeInternal) {
+			all = append(all, c)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+	total := len(all)
+
+	var selected []*models.Comment
+	descending := false
+	switch {
+	case page.After > 0:
+		for _, c := range all {
+			if c.ID > page.After {
+				selected = append(selected, c)
+			}
+		}
+	case page.Before > 0:
+		for i := len(all) - 1; i >= 0; i-- {
+			if all[i].ID < page.Before {
+				selected = append(selected, all[i])
+			}
+		}
+		descending = true
+	case page.Latest:
+		for i := len(all) - 1; i >= 0; i-- {
+			selected = append(selected, all[i])
+		}
+		descending = true
+	default:
+		selected = all
+	}
+
+	hasMore := page.Limit > 0 && len(selected) > page.Limit
+	if hasMore {
+		selected = selected[:page.Limit]
+	}
+
+	var comments []*models.Comment
+	for _, c := range selected {
+		if row := s.commentRow(c); row != nil {
+			comments = append(comments, row)
+		}
+	}
+	if descending {
+		for i, j := 0, len(comments)-1; i < j; i, j = i+1, j-1 {
+			comments[i], comments[j] = comments[j], comments[i]
+		}
+	}
+	return comments, total, hasMore, nil
+}
+
+func (s *Store) GetComment(ctx context.Context, id int64) (*models.Comment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.comments[id]
+	if c == nil {
+		return nil, nil
+	}
+	row := *c
+	row.Images = append([]string{}, c.Images...)
+	return &row, nil
+}
+
+func (s *Store) SetCommentOfficial(ctx context.Context, id int64, official bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c := s.comments[id]; c != nil && c.IsOfficial != official {
+		c.IsOfficial = official
+		s.touchCard(c.CardID)
+	}
+	return nil
+}
+
+// GetOfficialResponses returns the comments marked as official responses on a card, newest first
+func (s *Store) GetOfficialResponses(ctx context.Context, cardID int64) ([]*models.Comment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var comments []*models.Comment
+	for _, c := range s.comments {
+		if c.CardID != cardID || !c.IsOfficial || c.IsInternal {
+			continue
+		}
+		if row := s.commentRow(c); row != nil {
+			comments = append(comments, row)
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool {
+		if !comments[i].CreatedAt.Equal(comments[j].CreatedAt) {
+			return comments[i].CreatedAt.After(comments[j].CreatedAt)
+		}
+		return comments[i].ID > comments[j].ID
+	})
+	return comments, nil
+}
+
+// GetStaffActivity counts comments, internal ones included, written by the given users since a point in time
+func (s *Store) GetStaffActivity(ctx context.Context, userIDs []int64, since time.Time) ([]*models.StaffActivity, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var activity []*models.StaffActivity
+	seen := make(map[int64]bool)
+	for _, id := range userIDs {
+		author := s.author(id)
+		if author == nil || seen[id] {
+			continue
+		}
+		seen[id] = true
+		author.IsAdmin = true
+		a := &models.StaffActivity{User: author}
+		for _, c := range s.comments {
+			if c.UserID == id && !c.CreatedAt.Before(since) {
+				a.Comments++
+				if c.IsInternal {
+					a.InternalComments++
+				}
+			}
+		}
+		activity = append(activity, a)
+	}
+	sort.Slice(activity, func(i, j int) bool {
+		if activity[i].Comments != activity[j].Comments {
+			return activity[i].Comments > activity[j].Comments
+		}
+		return activity[i].User.ID < activity[j].User.ID
+	})
+	return activity, nil
+}
+
+func (s *Store) DeleteComment(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.comments[id]
+	if c == nil {
+		return nil
+	}
+	if card := s.cards[c.CardID]; card != nil {
+		s.countComment(card, c.IsInternal, -1)
+	}
+	s.deleteComment(id)
+	return nil
+}
+
+// deleteComment removes a comment with its reactions and mentions, leaving the counters alone
+func (s *Store) deleteComment(id int64) {
+	delete(s.comments, id)
+	s.commentReactions = withoutReactions(s.commentReactions, id)
+	s.mentions = withoutMentions(s.mentions, func(m *mention) bool { return m.commentID == id })
+	s.dropNotifications(func(n *models.Notification) bool { return n.CommentID == id })
+}
@@ -0,0 +1,779 @@
+// Package memory is an in-memory repository.Store for tests. It keeps the semantics
+// of the PostgreSQL repository: foreign keys, cascading deletes, stored counters and
+// ranking scores, vote toggling and review, keyset pagination and full-text search
+// (approximated, see text.go).
+package memory
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"bugtracker/internal/models"
+	"bugtracker/internal/repository"
+)
+
+// Errors standing in for constraint violations
+var (
+	ErrForeignKey = errors.New("memory: referenced row does not exist")
+	ErrDuplicate  = errors.New("memory: duplicate key")
+	ErrNotNull    = errors.New("memory: null value in NOT NULL column")
+)
+
+type user struct {
+	models.User
+	createdAt time.Time
+}
+
+// card holds the columns of a card row; joined fields of models.Card are left empty
+type card struct {
+	models.Card
+	hotScore             float64
+	bestScore            float64
+	controversyScore     float64
+	commentCount         int
+	internalCommentCount int
+	tags                 []string
+	updatedAt            time.Time
+}
+
+type voteKey struct {
+	userID, cardID int64
+}
+
+// reaction is a row of card_reactions or comment_reactions; slices of them are kept
+// in insertion order, which stands in for created_at
+type reaction struct {
+	targetID int64
+	userID   int64
+	emoji    string
+}
+
+type mention struct {
+	id        int64
+	userID    int64
+	cardID    int64
+	commentID int64
+	authorID  int64
+	createdAt time.Time
+}
+
+// Store is an in-memory repository.Store. The zero value is not usable; call New.
+type Store struct {
+	mu sync.Mutex
+
+	users            map[int64]*user
+	cards            map[int64]*card
+	votes            map[voteKey]*models.Vote
+	comments         map[int64]*models.Comment
+	views            map[int64]*models.SavedView
+	cardReactions    []reaction
+	commentReactions []reaction
+	mentions         []*mention
+
+	lastCardID    int64
+	lastCommentID int64
+	lastViewID    int64
+	lastMentionID int64
+	cardsVersion  int64 // as the cards_version table
+
+	notifications      map[int64]*models.Notification
+	lastNotificationID int64
+	preferences        map[int64]*models.NotificationPreferences
+}
+
+var _ repository.Store = (*Store)(nil)
+
+func New() *Store {
+	return &Store{
+		users:    make(map[int64]*user),
+		cards:    make(map[int64]*card),
+		votes:    make(map[voteKey]*models.Vote),
+		comments: make(map[int64]*models.Comment),
+		views:    make(map[int64]*models.SavedView),
+
+		notifications: make(map[int64]*models.Notification),
+		preferences:   make(map[int64]*models.NotificationPreferences),
+	}
+}
+
+// now returns the current time at PostgreSQL's microsecond precision
+func now() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+// SetUserCreatedAt backdates a user's account, which the vote rules look at
+func (s *Store) SetUserCreatedAt(userID int64, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u := s.users[userID]; u != nil {
+		u.createdAt = t
+	}
+}
+
+// SetCardTags replaces the tags of a card; the API has no way to set them
+func (s *Store) SetCardTags(cardID int64, tags ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c := s.cards[cardID]; c != nil {
+		c.tags = append([]string(nil), tags...)
+	}
+}
+
+// User operations
+func (s *Store) UpsertUser(ctx context.Context, u *models.User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing := s.users[u.ID]; existing != nil {
+		existing.FirstName, existing.LastName = u.FirstName, u.LastName
+		existing.Username, existing.PhotoURL = u.Username, u.PhotoURL
+		existing.AuthDate = u.AuthDate
+		return nil
+	}
+	s.users[u.ID] = &user{User: *u, createdAt: now()}
+	return nil
+}
+
+func (s *Store) GetUser(ctx context.Context, id int64) (*models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u := s.users[id]
+	if u == nil {
+		return nil, nil
+	}
+	copied := u.User
+	return &copied, nil
+}
+
+// author returns a user as joined into cards, comments and votes, nil if missing
+func (s *Store) author(id int64) *models.User {
+	u := s.users[id]
+	if u == nil {
+		return nil
+	}
+	return &models.User{ID: u.ID, FirstName: u.FirstName, LastName: u.LastName, Username: u.Username, PhotoURL: u.PhotoURL}
+}
+
+func (s *Store) GetUsersByUsernames(ctx context.Context, usernames []string) ([]*models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wanted := make(map[string]bool, len(usernames))
+	for _, name := range usernames {
+		wanted[strings.ToLower(name)] = true
+	}
+	var users []*models.User
+	for _, u := range s.users {
+		if u.Username != "" && wanted[strings.ToLower(u.Username)] {
+			copied := u.User
+			users = append(users, &copied)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+// Card operations
+
+// CreateCard creates a card with the mentions in its description and the notifications
+// about it, whose CardID is set to the new card's
+func (s *Store) CreateCard(ctx context.Context, c *models.Card, mentioned []int64, notify []*models.Notification) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.users[c.UserID] == nil || !s.usersExist(mentioned) || !s.notificationsValid(notify, true) {
+		return ErrForeignKey
+	}
+	s.lastCardID++
+	c.ID = s.lastCardID
+
+	stored := &card{Card: models.Card{
+		ID:          c.ID,
+		UserID:      c.UserID,
+		Title:       c.Title,
+		Description: c.Description,
+		Type:        c.Type,
+		Status:      c.Status,
+		Images:      append([]string{}, c.Images...),
+		CreatedAt:   now(),
+	}}
+	stored.hotScore = hotScore(0, 0, stored.CreatedAt)
+	stored.updatedAt = stored.CreatedAt
+	s.cards[c.ID] = stored
+	s.cardsVersion++
+
+	s.createMentions(c.ID, 0, c.UserID, mentioned)
+	for _, n := range notify {
+		n.CardID = c.ID
+	}
+	s.enqueueNotifications(notify)
+	return nil
+}
+
+// touchCard sets the updated_at of a card after a change to what is shown with it, if it exists
+func (s *Store) touchCard(id int64) {
+	if c := s.cards[id]; c != nil {
+		s.cardChanged(c)
+	}
+}
+
+// cardChanged sets the updated_at of a card after a change to it or to what is shown with
+// it, and advances the version of the card lists
+func (s *Store) cardChanged(c *card) {
+	c.updatedAt = now()
+	s.cardsVersion++
+}
+
+// cardRow returns a card with its joined fields, as selected by the repository.
+// Cards whose author is missing are skipped by the join and come back nil.
+func (s *Store) cardRow(c *card, includeInternal bool) *models.Card {
+	author := s.author(c.UserID)
+	if author == nil {
+		return nil
+	}
+	row := c.Card
+	row.Images = append([]string{}, c.Images...)
+	row.Author = author
+	row.CommentCount = c.commentCount
+	if includeInternal {
+		row.CommentCount += c.internalCommentCount
+	}
+	for _, cm := range s.comments {
+		if cm.CardID == c.ID && cm.IsOfficial {
+			row.HasOfficialResponse = true
+			break
+		}
+	}
+	return &row
+}
+
+// GetCard returns a card by ID. Internal comments are only counted when includeInternal is set.
+func (s *Store) GetCard(ctx context.Context, id int64, includeInternal bool) (*models.Card, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.cards[id]
+	if c == nil {
+		return nil, nil
+	}
+	return s.cardRow(c, includeInternal), nil
+}
+
+// cardMatches applies the filter to a card, leaving out the facet named by skip.
+// q is the parsed filter.Query.
+func (s *Store) cardMatches(c *card, filter repository.CardFilter, q query, skip string) bool {
+	author := s.users[c.UserID]
+	if author == nil {
+		return false
+	}
+	if filter.Query != "" && !q.matches(c.Title+"\n"+c.Description) {
+		return false
+	}
+	if len(filter.Types) > 0 && skip != "type" && !contains(filter.Types, c.Type) {
+		return false
+	}
+	if len(filter.Statuses) > 0 && skip != "status" && !contains(filter.Statuses, c.Status) {
+		return false
+	}
+	for _, tag := range filter.Tags {
+		if !containsFold(c.tags, tag) {
+			return false
+		}
+	}
+	if len(filter.Authors) > 0 && (author.Username == "" || !containsFold(filter.Authors, author.Username)) {
+		return false
+	}
+	if filter.MinRating != nil && c.Rating < *filter.MinRating {
+		return false
+	}
+	if filter.MaxRating != nil && c.Rating > *filter.MaxRating {
+		return false
+	}
+	if !filter.CreatedFrom.IsZero() && c.CreatedAt.Before(filter.CreatedFrom) {
+		return false
+	}
+	if !filter.CreatedTo.IsZero() && !c.CreatedAt.Before(filter.CreatedTo) {
+		return false
+	}
+	return true
+}
+
+func contains(values []string, v string) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, v string) bool {
+	for _, x := range values {
+		if strings.EqualFold(x, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// cardRank approximates ts_rank_cd of the card's search vector
+func cardRank(c *card, q query) float64 {
+	return q.rank(c.Title, weightTitle) + q.rank(c.Description, weightDescription)
+}
+
+// sortKey returns the value cards are sorted by, descending and then by id (see
+// repository's sortKey). Times are taken in microseconds, which a float64 holds exactly.
+func sortKey(c *card, sort string, q query) float64 {
+	switch sort {
+	case "time":
+		return float64(c.CreatedAt.UnixMicro())
+	case "hot":
+		return c.hotScore
+	case "best":
+		return c.bestScore
+	case "controversial":
+		return c.controversyScore
+	case "most_discussed":
+		return float64(c.commentCount)
+	case "relevance":
+		if q != nil {
+			return cardRank(c, q)
+		}
+	}
+	return float64(c.Rating)
+}
+
+// Cursor keys are the sort key as text: RFC 3339 for times, a number otherwise
+func formatKey(key float64, sort string) string {
+	if sort == "time" {
+		return time.UnixMicro(int64(key)).UTC().Format(time.RFC3339Nano)
+	}
+	return strconv.FormatFloat(key, 'g', -1, 64)
+}
+
+func parseKey(text, sort string) (float64, error) {
+	if sort == "time" {
+		t, err := time.Parse(time.RFC3339Nano, text)
+		return float64(t.UnixMicro()), err
+	}
+	return strconv.ParseFloat(text, 64)
+}
+
+// ListCards lists cards matching the filter. A non-empty Query is a full-text search;
+// matching cards get TitleHighlight and Snippet, and the "relevance" sort becomes available.
+// next is the cursor of the following page, nil on the last page.
+func (s *Store) ListCards(ctx context.Context, filter repository.CardFilter, sortBy string, page repository.CardPage, userID int64, includeInternal bool) (cards []*models.Card, total int, next *repository.CardCursor, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, nil, err
+	}
+	if page.After != nil && page.After.Sort != sortBy {
+		return nil, 0, nil, repository.ErrInvalidCursor
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var q query
+	if filter.Query != "" {
+		q = parseQuery(filter.Query)
+	}
+
+	type keyed struct {
+		card *card
+		key  float64
+	}
+	var matched []keyed
+	for _, c := range s.cards {
+		if s.cardMatches(c, filter, q, "") {
+			matched = append(matched, keyed{c, sortKey(c, sortBy, q)})
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].key != matched[j].key {
+			return matched[i].key > matched[j].key
+		}
+		return matched[i].card.ID > matched[j].card.ID
+	})
+
+	total = -1
+	if page.Total != repository.TotalNone {
+		// The estimate is exact here
+		total = len(matched)
+	}
+
+	if page.After != nil {
+		after, err := parseKey(page.After.Key, sortBy)
+		if err != nil {
+			return nil, 0, nil, repository.ErrInvalidCursor
+		}
+		i := sort.Search(len(matched), func(i int) bool {
+			m := matched[i]
+			return m.key < after || (m.key == after && m.card.ID < page.After.ID)
+		})
+		matched = matched[i:]
+	} else if page.Offset < len(matched) {
+		matched = matched[page.Offset:]
+	} else {
+		matched = nil
+	}
+
+	for i, m := range matched {
+		if i == page.Limit {
+			last := matched[i-1]
+			next = &repository.CardCursor{Sort: sortBy, Key: formatKey(last.key, sortBy), ID: last.card.ID}
+			break
+		}
+		row := s.cardRow(m.card, includeInternal)
+		if v := s.votes[voteKey{userID, m.card.ID}]; v != nil {
+			row.UserVote = v.Value
+		}
+		if q != nil {
+			row.TitleHighlight = q.highlight(row.Title)
+			row.Snippet = q.highlight(row.Description)
+		}
+		cards = append(cards, row)
+	}
+	return cards, total, next, nil
+}
+
+// CountCards returns the number of cards matching a filter
+func (s *Store) CountCards(ctx context.Context, filter repository.CardFilter) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q := parseQuery(filter.Query)
+	n := 0
+	for _, c := range s.cards {
+		if s.cardMatches(c, filter, q, "") {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// CountCardsBatch counts the cards matching each of the filters
+func (s *Store) CountCardsBatch(ctx context.Context, filters []repository.CardFilter) ([]int, error) {
+	counts := make([]int, len(filters))
+	for i, filter := range filters {
+		n, err := s.CountCards(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		counts[i] = n
+	}
+	return counts, nil
+}
+
+// CardFacets counts the cards matching the filter per status, type and tag. Status and
+// type counts ignore the filter's own statuses and types.
+func (s *Store) CardFacets(ctx context.Context, filter repository.CardFilter) (*models.CardFacets, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q := parseQuery(filter.Query)
+	statuses, types, tags := map[string]int{}, map[string]int{}, map[string]int{}
+	for _, c := range s.cards {
+		if s.cardMatches(c, filter, q, "status") {
+			statuses[c.Status]++
+		}
+		if s.cardMatches(c, filter, q, "type") {
+			types[c.Type]++
+		}
+		if s.cardMatches(c, filter, q, "") {
+			for _, tag := range c.tags {
+				tags[tag]++
+			}
+		}
+	}
+	return &models.CardFacets{
+		Statuses: facetCounts(statuses),
+		Types:    facetCounts(types),
+		Tags:     facetCounts(tags),
+	}, nil
+}
+
+// CardsVersion returns a token that changes whenever any card does
+// (see repository.Repository.CardsVersion)
+func (s *Store) CardsVersion(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return repository.ListVersion(s.cardsVersion), nil
+}
+
+// CardVersion is CardsVersion for a single card; it returns "" if the card does not exist
+func (s *Store) CardVersion(ctx context.Context, id int64) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.cards[id]
+	if c == nil {
+		return "", nil
+	}
+	return repository.Version(1, c.updatedAt, int64(c.Likes+c.Dislikes+c.commentCount+c.internalCommentCount)), nil
+}
+
+// facetCounts orders counts by count descending, then by value
+func facetCounts(counts map[string]int) []models.FacetCount {
+	out := []models.FacetCount{}
+	for value, n := range counts {
+		out = append(out, models.FacetCount{Value: value, Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Value < out[j].Value
+	})
+	return out
+}
+
+// Search finds cards matching query by their own text or by their comments, best matches
+// first. Each result carries up to commentsPerCard best matching comments with excerpts.
+func (s *Store) Search(ctx context.Context, text string, limit, offset, commentsPerCard int, includeInternal bool) ([]*models.SearchResult, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q := parseQuery(text)
+
+	type commentHit struct {
+		comment *models.Comment
+		rank    float64
+	}
+	type hit struct {
+		card     *card
+		rank     float64
+		match    bool
+		comments []commentHit
+	}
+	hits := make(map[int64]*hit)
+	for _, c := range s.cards {
+		if q.matches(c.Title + "\n" + c.Description) {
+			hits[c.ID] = &hit{card: c, rank: cardRank(c, q), match: true}
+		}
+	}
+	for _, cm := range s.comments {
+		if (cm.IsInternal && !includeInternal) || !q.matches(cm.Content) {
+			continue
+		}
+		h := hits[cm.CardID]
+		if h == nil {
+			h = &hit{card: s.cards[cm.CardID]}
+			hits[cm.CardID] = h
+		}
+		rank := q.rank(cm.Content, weightComment)
+		h.comments = append(h.comments, commentHit{cm, rank})
+		h.rank = math.Max(h.rank, rank)
+	}
+
+	ranked := make([]*hit, 0, len(hits))
+	for _, h := range hits {
+		ranked = append(ranked, h)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].rank != ranked[j].rank {
+			return ranked[i].rank > ranked[j].rank
+		}
+		return ranked[i].card.ID > ranked[j].card.ID
+	})
+
+	var results []*models.SearchResult
+	for _, h := range page(ranked, limit, offset) {
+		res := &models.SearchResult{
+			CardID:         h.card.ID,
+			Title:          q.highlight(h.card.Title),
+			Type:           h.card.Type,
+			Status:         h.card.Status,
+			CardMatch:      h.match,
+			CommentMatches: len(h.comments),
+			Comments:       []*models.CommentMatch{},
+		}
+		if h.match {
+			res.Snippet = q.highlight(h.card.Description)
+		}
+
+		sort.Slice(h.comments, func(i, j int) bool {
+			if h.comments[i].rank != h.comments[j].rank {
+				return h.comments[i].rank > h.comments[j].rank
+			}
+			return h.comments[i].comment.ID < h.comments[j].comment.ID
+		})
+		for i, ch := range h.comments {
+			if i >= commentsPerCard {
+				break
+			}
+			author := s.author(ch.comment.UserID)
+			if author == nil {
+				continue
+			}
+			res.Comments = append(res.Comments, &models.CommentMatch{
+				ID:         ch.comment.ID,
+				Excerpt:    q.highlight(ch.comment.Content),
+				IsInternal: ch.comment.IsInternal,
+				CreatedAt:  ch.comment.CreatedAt,
+				Author:     author,
+			})
+		}
+		results = append(results, res)
+	}
+	return results, len(ranked), nil
+}
+
+// FindSimilarCards returns open cards whose title resembles title, best matches first
+func (s *Store) FindSimilarCards(ctx context.Context, title string, opts repository.SimilarCardsOptions) ([]*models.SimilarCard, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q := anyWord(title)
+	var cards []*models.SimilarCard
+	for _, c := range s.cards {
+		if c.Status == "closed" || c.Status == "fixed" || (opts.Type != "" && c.Type != opts.Type) {
+			continue
+		}
+		sim := similarity(c.Title, title)
+		matches := q.matches(c.Title + "\n" + c.Description)
+		var rank float64
+		if matches {
+			rank = cardRank(c, q)
+		}
+		if sim < opts.MinSimilarity && !(matches && rank >= opts.MinRank) {
+			continue
+		}
+		cards = append(cards, &models.SimilarCard{
+			ID: c.ID, Title: c.Title, Type: c.Type, Status: c.Status,
+			Rating: c.Rating, Likes: c.Likes, Dislikes: c.Dislikes,
+			Similarity: sim, Rank: rank,
+		})
+	}
+	sort.Slice(cards, func(i, j int) bool {
+		a, b := cards[i].Similarity+cards[i].Rank, cards[j].Similarity+cards[j].Rank
+		if a != b {
+			return a > b
+		}
+		return cards[i].ID > cards[j].ID
+	})
+	if len(cards) > opts.Limit {
+		cards = cards[:opts.Limit]
+	}
+	return cards, nil
+}
+
+// UpdateCardStatus changes the status of a card and queues the notifications about it
+func (s *Store) UpdateCardStatus(ctx context.Context, id int64, status string, notify []*models.Notification) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.notificationsValid(notify, false) {
+		return ErrForeignKey
+	}
+	if c := s.cards[id]; c != nil {
+		c.Status = status
+		s.cardChanged(c)
+	}
+	s.enqueueNotifications(notify)
+	return nil
+}
+
+// DeleteCard removes a card with its comments, votes, tags, reactions and mentions
+func (s *Store) DeleteCard(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, cm := range s.comments {
+		if cm.CardID == id {
+			s.deleteComment(cm.ID)
+		}
+	}
+	for key := range s.votes {
+		if key.cardID == id {
+			delete(s.votes, key)
+		}
+	}
+	s.cardReactions = withoutReactions(s.cardReactions, id)
+	s.mentions = withoutMentions(s.mentions, func(m *mention) bool { return m.cardID == id })
+	s.dropNotifications(func(n *models.Notification) bool { return n.CardID == id })
+	if s.cards[id] != nil {
+		delete(s.cards, id)
+		s.cardsVersion++
+	}
+	return nil
+}
+
+// Ranking scores, as the card_*_score functions of migration 009
+
+func hotScore(likes, dislikes int, createdAt time.Time) float64 {
+	net := likes - dislikes
+	sign := 0.0
+	if net > 0 {
+		sign = 1
+	} else if net < 0 {
+		sign = -1
+	}
+	magnitude := math.Max(math.Abs(float64(net)), 1)
+	return sign*math.Log10(magnitude) + float64(createdAt.UnixMicro())/1e6/45000
+}
+
+func bestScore(likes, dislikes int) float64 {
+	n := float64(likes + dislikes)
+	if n == 0 {
+		return 0
+	}
+	l, d := float64(likes), float64(dislikes)
+	return ((l+1.9208)/n - 1.96*math.Sqrt(l*d/n+0.9604)/n) / (1 + 3.8416/n)
+}
+
+func controversyScore(likes, dislikes int) float64 {
+	if likes == 0 || dislikes == 0 {
+		return 0
+	}
+	l, d := float64(likes), float64(dislikes)
+	return math.Pow(l+d, math.Min(l, d)/math.Max(l, d))
+}
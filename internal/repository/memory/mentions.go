@@ -0,0 +1,96 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"bugtracker/internal/models"
+)
+
+// createMentions records that userIDs were mentioned by authorID on a card,
+// or in one of its comments when commentID is non-zero. Existing mentions are kept.
+// The users are checked by the caller (see usersExist).
+func (s *Store) createMentions(cardID, commentID, authorID int64, userIDs []int64) {
+next:
+	for _, userID := range userIDs {
+		for _, m := range s.mentions {
+			if m.userID == userID && m.cardID == cardID && m.commentID == commentID {
+				continue next
+			}
+		}
+		s.lastMentionID++
+		s.mentions = append(s.mentions, &mention{
+			id:        s.lastMentionID,
+			userID:    userID,
+			cardID:    cardID,
+			commentID: commentID,
+			authorID:  authorID,
+			createdAt: now(),
+		})
+	}
+}
+
+// usersExist tells whether every user in ids exists
+func (s *Store) usersExist(ids []int64) bool {
+	for _, id := range ids {
+		if s.users[id] == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Store) ListMentions(ctx context.Context, userID int64, limit, offset int) ([]*models.Mention, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matched []*mention
+	for _, m := range s.mentions {
+		if m.userID == userID {
+			matched = append(matched, m)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].createdAt.Equal(matched[j].createdAt) {
+			return matched[i].createdAt.After(matched[j].createdAt)
+		}
+		return matched[i].id > matched[j].id
+	})
+
+	var mentions []*models.Mention
+	for _, m := range page(matched, limit, offset) {
+		c, author := s.cards[m.cardID], s.author(m.authorID)
+		if c == nil || author == nil {
+			continue
+		}
+		content := c.Description
+		if cm := s.comments[m.commentID]; cm != nil {
+			content = cm.Content
+		}
+		mentions = append(mentions, &models.Mention{
+			ID:        m.id,
+			UserID:    m.userID,
+			CardID:    m.cardID,
+			CardTitle: c.Title,
+			CommentID: m.commentID,
+			Content:   content,
+			CreatedAt: m.createdAt,
+			Author:    author,
+		})
+	}
+	return mentions, len(matched), nil
+}
+
+// withoutMentions drops the mentions for which gone reports true
+func withoutMentions(mentions []*mention, gone func(m *mention) bool) []*mention {
+	out := mentions[:0]
+	for _, m := range mentions {
+		if !gone(m) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
@@ -0,0 +1,218 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"bugtracker/internal/models"
+	"bugtracker/internal/repository"
+)
+
+// notificationsValid checks the references of notifications about to be queued.
+// newCard skips the card, which is being created with them.
+func (s *Store) notificationsValid(notify []*models.Notification, newCard bool) bool {
+	for _, n := range notify {
+		if s.users[n.UserID] == nil || (n.ActorID != 0 && s.users[n.ActorID] == nil) {
+			return false
+		}
+		if !newCard && s.cards[n.CardID] == nil {
+			return false
+		}
+		if n.CommentID != 0 && s.comments[n.CommentID] == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// enqueueNotifications stores notifications as pending and due now
+func (s *Store) enqueueNotifications(notify []*models.Notification) {
+	for _, n := range notify {
+		s.lastNotificationID++
+		n.ID = s.lastNotificationID
+		n.State = repository.NotificationPending
+		n.CreatedAt = now()
+		n.NextAttemptAt = n.CreatedAt
+		stored := *n
+		s.notifications[n.ID] = &stored
+	}
+}
+
+// dropNotifications deletes the notifications for which gone reports true
+func (s *Store) dropNotifications(gone func(n *models.Notification) bool) {
+	for id, n := range s.notifications {
+		if gone(n) {
+			delete(s.notifications, id)
+		}
+	}
+}
+
+// sortedNotifications returns the notifications matching keep, ordered by less
+func (s *Store) sortedNotifications(keep func(n *models.Notification) bool, less func(a, b *models.Notification) bool) []*models.Notification {
+	var matched []*models.Notification
+	for _, n := range s.notifications {
+		if keep(n) {
+			matched = append(matched, n)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return less(matched[i], matched[j]) })
+	return matched
+}
+
+// ClaimNotifications takes up to limit pending notifications that are due, oldest first,
+// counts an attempt for each and hands them out again only after lease
+func (s *Store) ClaimNotifications(ctx context.Context, limit int, lease time.Duration) ([]*models.Notification, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := now()
+	due := s.sortedNotifications(
+		func(n *models.Notification) bool {
+			return n.State == repository.NotificationPending && !n.NextAttemptAt.After(t)
+		},
+		func(a, b *models.Notification) bool {
+			if !a.NextAttemptAt.Equal(b.NextAttemptAt) {
+				return a.NextAttemptAt.Before(b.NextAttemptAt)
+			}
+			return a.ID < b.ID
+		},
+	)
+
+	var batch []*models.Notification
+	for _, n := range page(due, limit, 0) {
+		n.Attempts++
+		n.NextAttemptAt = t.Add(lease)
+		claimed := *n
+		batch = append(batch, &claimed)
+	}
+	return batch, nil
+}
+
+// DeleteNotification removes a delivered notification from the outbox
+func (s *Store) DeleteNotification(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.notifications, id)
+	return nil
+}
+
+// FailNotification records a failed delivery and schedules the next attempt at retryAt.
+// A zero retryAt moves the notification to the dead letters.
+func (s *Store) FailNotification(ctx context.Context, id int64, lastError string, retryAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.notifications[id]
+	if n == nil {
+		return nil
+	}
+	n.LastError = lastError
+	if retryAt.IsZero() {
+		n.State = repository.NotificationDead
+	} else {
+		n.NextAttemptAt = retryAt.Truncate(time.Microsecond)
+	}
+	return nil
+}
+
+// DeferNotification puts off a claimed notification until a given time without counting the attempt
+func (s *Store) DeferNotification(ctx context.Context, id int64, until time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.notifications[id]
+	if n == nil {
+		return nil
+	}
+	if n.Attempts > 0 {
+		n.Attempts--
+	}
+	n.NextAttemptAt = until.Truncate(time.Microsecond)
+	return nil
+}
+
+// ListNotifications returns the notifications in a state, newest first
+func (s *Store) ListNotifications(ctx context.Context, state string, limit, offset int) ([]*models.Notification, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matched := s.sortedNotifications(
+		func(n *models.Notification) bool { return n.State == state },
+		func(a, b *models.Notification) bool {
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.After(b.CreatedAt)
+			}
+			return a.ID > b.ID
+		},
+	)
+
+	var notifications []*models.Notification
+	for _, n := range page(matched, limit, offset) {
+		c := s.cards[n.CardID]
+		if c == nil {
+			continue
+		}
+		row := *n
+		row.CardTitle = c.Title
+		notifications = append(notifications, &row)
+	}
+	return notifications, len(matched), nil
+}
+
+// RetryNotification puts a dead notification back in the queue with its attempts reset.
+// Returns false if there is no dead notification with that ID.
+func (s *Store) RetryNotification(ctx context.Context, id int64) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.notifications[id]
+	if n == nil || n.State != repository.NotificationDead {
+		return false, nil
+	}
+	s.requeue(n)
+	return true, nil
+}
+
+// RetryDeadNotifications puts every dead notification back in the queue and returns how many
+func (s *Store) RetryDeadNotifications(ctx context.Context) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var retried int64
+	for _, n := range s.notifications {
+		if n.State == repository.NotificationDead {
+			s.requeue(n)
+			retried++
+		}
+	}
+	return retried, nil
+}
+
+func (s *Store) requeue(n *models.Notification) {
+	n.State = repository.NotificationPending
+	n.Attempts = 0
+	n.NextAttemptAt = now()
+}
@@ -0,0 +1,39 @@
+package memory
+
+import (
+	"context"
+
+	"bugtracker/internal/models"
+)
+
+func (s *Store) GetNotificationPreferences(ctx context.Context, userID int64) (*models.NotificationPreferences, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.preferences[userID]
+	if p == nil {
+		return nil, nil
+	}
+	copied := *p
+	copied.Muted = append([]string{}, p.Muted...)
+	return &copied, nil
+}
+
+func (s *Store) SaveNotificationPreferences(ctx context.Context, p *models.NotificationPreferences) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.users[p.UserID] == nil {
+		return ErrForeignKey
+	}
+	stored := *p
+	stored.Muted = append([]string{}, p.Muted...)
+	s.preferences[p.UserID] = &stored
+	return nil
+}
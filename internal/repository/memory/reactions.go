@@ -0,0 +1,152 @@
+package memory
+
+import (
+	"context"
+
+	"bugtracker/internal/models"
+)
+
+// Reaction operations. An actual change touches the card the reaction is shown with.
+func (s *Store) AddCardReaction(ctx context.Context, cardID, userID int64, emoji string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cards[cardID] == nil || s.users[userID] == nil {
+		return ErrForeignKey
+	}
+	n := len(s.cardReactions)
+	if s.cardReactions = addReaction(s.cardReactions, reaction{cardID, userID, emoji}); len(s.cardReactions) != n {
+		s.touchCard(cardID)
+	}
+	return nil
+}
+
+func (s *Store) RemoveCardReaction(ctx context.Context, cardID, userID int64, emoji string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.cardReactions)
+	if s.cardReactions = removeReaction(s.cardReactions, reaction{cardID, userID, emoji}); len(s.cardReactions) != n {
+		s.touchCard(cardID)
+	}
+	return nil
+}
+
+func (s *Store) AddCommentReaction(ctx context.Context, commentID, userID int64, emoji string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.comments[commentID] == nil || s.users[userID] == nil {
+		return ErrForeignKey
+	}
+	n := len(s.commentReactions)
+	if s.commentReactions = addReaction(s.commentReactions, reaction{commentID, userID, emoji}); len(s.commentReactions) != n {
+		s.touchCard(s.comments[commentID].CardID)
+	}
+	return nil
+}
+
+func (s *Store) RemoveCommentReaction(ctx context.Context, commentID, userID int64, emoji string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.commentReactions)
+	if s.commentReactions = removeReaction(s.commentReactions, reaction{commentID, userID, emoji}); len(s.commentReactions) != n {
+		s.touchCard(s.comments[commentID].CardID)
+	}
+	return nil
+}
+
+// GetCardReactions returns aggregated reactions keyed by card ID
+func (s *Store) GetCardReactions(ctx context.Context, cardIDs []int64, userID int64) (map[int64][]models.Reaction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return aggregateReactions(s.cardReactions, cardIDs, userID), nil
+}
+
+// GetCommentReactions returns aggregated reactions keyed by comment ID
+func (s *Store) GetCommentReactions(ctx context.Context, commentIDs []int64, userID int64) (map[int64][]models.Reaction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return aggregateReactions(s.commentReactions, commentIDs, userID), nil
+}
+
+// addReaction appends r unless the user already left that emoji
+func addReaction(reactions []reaction, r reaction) []reaction {
+	for _, existing := range reactions {
+		if existing == r {
+			return reactions
+		}
+	}
+	return append(reactions, r)
+}
+
+func removeReaction(reactions []reaction, r reaction) []reaction {
+	out := reactions[:0]
+	for _, existing := range reactions {
+		if existing != r {
+			out = append(out, existing)
+		}
+	}
+	return out
+}
+
+// withoutReactions drops the reactions on a deleted card or comment
+func withoutReactions(reactions []reaction, targetID int64) []reaction {
+	out := reactions[:0]
+	for _, r := range reactions {
+		if r.targetID != targetID {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// aggregateReactions counts reactions per target and emoji, in order of the first reaction
+func aggregateReactions(reactions []reaction, ids []int64, userID int64) map[int64][]models.Reaction {
+	wanted := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	out := make(map[int64][]models.Reaction)
+	for _, r := range reactions {
+		if !wanted[r.targetID] {
+			continue
+		}
+		list := out[r.targetID]
+		i := 0
+		for i < len(list) && list[i].Emoji != r.emoji {
+			i++
+		}
+		if i == len(list) {
+			list = append(list, models.Reaction{Emoji: r.emoji})
+		}
+		list[i].Count++
+		if r.userID == userID {
+			list[i].Reacted = true
+		}
+		out[r.targetID] = list
+	}
+	return out
+}
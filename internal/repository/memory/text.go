@@ -0,0 +1,207 @@
+package memory
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Full-text search and trigram similarity approximating the PostgreSQL features the
+// repository relies on. There is no stemming: a query word matches every word it is
+// a prefix of, so "crash" finds "crashes" as the russian and english configurations would.
+
+// Weights of ts_rank_cd for the card title (A), description (B) and unweighted comments (D)
+const (
+	weightTitle       = 1.0
+	weightDescription = 0.4
+	weightComment     = 0.1
+)
+
+// term is a word or a quoted phrase of a query
+type term struct {
+	words   []string
+	exclude bool
+}
+
+// query is a search in web search syntax (see websearch_to_tsquery): alternatives
+// separated by OR, each a list of required and -excluded terms
+type query [][]term
+
+// words splits text into lowercase words of letters and digits
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func parseQuery(s string) query {
+	var q query
+	var group []term
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		if unicode.IsSpace(rs[i]) {
+			i++
+			continue
+		}
+		exclude := false
+		if rs[i] == '-' && i+1 < len(rs) && !unicode.IsSpace(rs[i+1]) {
+			exclude = true
+			i++
+		}
+		start, quoted := i, rs[i] == '"'
+		if quoted {
+			start = i + 1
+			for i = start; i < len(rs) && rs[i] != '"'; i++ {
+			}
+		} else {
+			for ; i < len(rs) && !unicode.IsSpace(rs[i]) && rs[i] != '"'; i++ {
+			}
+		}
+		token := string(rs[start:i])
+		if i < len(rs) && rs[i] == '"' {
+			i++
+		}
+
+		if !exclude && !quoted && strings.EqualFold(token, "or") {
+			if len(group) > 0 {
+				q = append(q, group)
+				group = nil
+			}
+			continue
+		}
+		if w := words(token); len(w) > 0 {
+			group = append(group, term{words: w, exclude: exclude})
+		}
+	}
+	if len(group) > 0 {
+		q = append(q, group)
+	}
+	return q
+}
+
+// occurrences counts the positions where the term starts in text words
+func (t term) occurrences(text []string) int {
+	n := 0
+	for i := 0; i+len(t.words) <= len(text); i++ {
+		if t.at(text, i) {
+			n++
+		}
+	}
+	return n
+}
+
+func (t term) at(text []string, i int) bool {
+	for j, w := range t.words {
+		if !strings.HasPrefix(text[i+j], w) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether text satisfies the query. An empty query matches nothing.
+func (q query) matches(text string) bool {
+	tw := words(text)
+	for _, group := range q {
+		ok := true
+		for _, t := range group {
+			if (t.occurrences(tw) > 0) == t.exclude {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// rank sums the occurrences of the query's required terms in text, times weight
+func (q query) rank(text string, weight float64) float64 {
+	tw := words(text)
+	n := 0
+	for _, group := range q {
+		for _, t := range group {
+			if !t.exclude {
+				n += t.occurrences(tw)
+			}
+		}
+	}
+	return float64(n) * weight
+}
+
+// highlight HTML-escapes text and wraps the words matching the query in <mark>,
+// as the repository does with ts_headline results
+func (q query) highlight(text string) string {
+	var b strings.Builder
+	rs := []rune(text)
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+	for i := 0; i < len(rs); {
+		j := i
+		for j < len(rs) && isWord(rs[j]) == isWord(rs[i]) {
+			j++
+		}
+		chunk := string(rs[i:j])
+		if isWord(rs[i]) && q.marks(strings.ToLower(chunk)) {
+			b.WriteString("<mark>" + html.EscapeString(chunk) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(chunk))
+		}
+		i = j
+	}
+	return b.String()
+}
+
+func (q query) marks(word string) bool {
+	for _, group := range q {
+		for _, t := range group {
+			if t.exclude {
+				continue
+			}
+			for _, w := range t.words {
+				if strings.HasPrefix(word, w) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// anyWord is the query used for similar cards: any word of text may match
+func anyWord(text string) query {
+	var q query
+	for _, w := range words(text) {
+		q = append(q, []term{{words: []string{w}}})
+	}
+	return q
+}
+
+// trigrams returns the pg_trgm trigrams of text: every word is padded with
+// two spaces in front and one behind
+func trigrams(text string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range words(text) {
+		rs := []rune("  " + w + " ")
+		for i := 0; i+3 <= len(rs); i++ {
+			set[string(rs[i:i+3])] = true
+		}
+	}
+	return set
+}
+
+// similarity is pg_trgm's similarity(): shared trigrams over all distinct trigrams
+func similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQueryMatches(t *testing.T) {
+	tests := []struct {
+		query string
+		text  string
+		want  bool
+	}{
+		{"crash", "The app crashes on start", true},
+		{"crash start", "The app crashes on start", true},
+		{"crash exit", "The app crashes on start", false},
+		{`"app crashes"`, "The app crashes on start", true},
+		{`"crashes app"`, "The app crashes on start", false},
+		{"crash -start", "The app crashes on start", false},
+		{"exit or start", "The app crashes on start", true},
+		{"Падает", "Приложение падает при запуске", true},
+		{"", "anything", false},
+		{"!!!", "anything", false},
+	}
+	for _, tt := range tests {
+		if got := parseQuery(tt.query).matches(tt.text); got != tt.want {
+			t.Errorf("%q matches %q = %v, want %v", tt.query, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestQueryHighlight(t *testing.T) {
+	got := parseQuery("crash -start").highlight("<b>Crashes</b> on start")
+	if want := "&lt;b&gt;<mark>Crashes</mark>&lt;/b&gt; on start"; got != want {
+		t.Errorf("highlight = %q, want %q", got, want)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	if got := similarity("word", "word"); got != 1 {
+		t.Errorf("identical = %v", got)
+	}
+	if got := similarity("word", ""); got != 0 {
+		t.Errorf("empty = %v", got)
+	}
+	// pg_trgm: similarity('word', 'words') is 4 shared of 7 distinct trigrams
+	if got := similarity("word", "words"); math.Abs(got-4.0/7) > 1e-9 {
+		t.Errorf("word/words = %v", got)
+	}
+}
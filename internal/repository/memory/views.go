@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"bugtracker/internal/models"
+)
+
+// Saved view operations
+func (s *Store) CreateSavedView(ctx context.Context, v *models.SavedView) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.users[v.UserID] == nil {
+		return ErrForeignKey
+	}
+	if v.Tags == nil {
+		return ErrNotNull
+	}
+	for _, existing := range s.views {
+		if existing.ShareToken == v.ShareToken {
+			return ErrDuplicate
+		}
+	}
+	s.lastViewID++
+	v.ID = s.lastViewID
+	s.views[v.ID] = copyView(v)
+	return nil
+}
+
+func copyView(v *models.SavedView) *models.SavedView {
+	copied := *v
+	copied.Tags = append([]string{}, v.Tags...)
+	return &copied
+}
+
+func (s *Store) UpdateSavedView(ctx context.Context, v *models.SavedView) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if v.Tags == nil {
+		return ErrNotNull
+	}
+	existing := s.views[v.ID]
+	if existing == nil {
+		return nil
+	}
+	updated := copyView(v)
+	updated.UserID, updated.ShareToken, updated.CreatedAt = existing.UserID, existing.ShareToken, existing.CreatedAt
+	s.views[v.ID] = updated
+	return nil
+}
+
+func (s *Store) DeleteSavedView(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.views, id)
+	return nil
+}
+
+func (s *Store) GetSavedView(ctx context.Context, id int64) (*models.SavedView, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if v := s.views[id]; v != nil {
+		return copyView(v), nil
+	}
+	return nil, nil
+}
+
+func (s *Store) GetSavedViewByToken(ctx context.Context, token string) (*models.SavedView, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, v := range s.views {
+		if v.ShareToken == token {
+			return copyView(v), nil
+		}
+	}
+	return nil, nil
+}
+
+// ListSavedViews returns the published views followed by the user's own views
+func (s *Store) ListSavedViews(ctx context.Context, userID int64) ([]*models.SavedView, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var views []*models.SavedView
+	for _, v := range s.views {
+		if v.IsPublic || v.UserID == userID {
+			views = append(views, copyView(v))
+		}
+	}
+	sort.Slice(views, func(i, j int) bool {
+		a, b := views[i], views[j]
+		if a.IsPublic != b.IsPublic {
+			return a.IsPublic
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID < b.ID
+	})
+	return views, nil
+}
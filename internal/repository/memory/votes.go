@@ -0,0 +1,328 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"bugtracker/internal/models"
+	"bugtracker/internal/repository"
+)
+
+// checkVote returns the reason a new vote looks suspicious, or an empty string
+// (see repository.VoteRules)
+func (s *Store) checkVote(rules repository.VoteRules, userID, cardID int64) string {
+	accountAge := time.Duration(0)
+	if u := s.users[userID]; u != nil && !u.createdAt.IsZero() {
+		accountAge = time.Since(u.createdAt)
+	}
+
+	if rules.MinAccountAge > 0 && accountAge < rules.MinAccountAge {
+		return "new_account"
+	}
+
+	if rules.UserBurstLimit > 0 && accountAge < rules.NewAccountAge {
+		since := time.Now().Add(-rules.UserBurstWindow)
+		recent := 0
+		for key, v := range s.votes {
+			if key.userID == userID && v.CreatedAt.After(since) {
+				recent++
+			}
+		}
+		if recent >= rules.UserBurstLimit {
+			return "user_burst"
+		}
+	}
+
+	if rules.CardBurstLimit > 0 {
+		since := time.Now().Add(-rules.CardBurstWindow)
+		recent := 0
+		for key, v := range s.votes {
+			if key.cardID == cardID && key.userID != userID && v.CreatedAt.After(since) {
+				recent++
+			}
+		}
+		if recent >= rules.CardBurstLimit {
+			return "card_burst"
+		}
+	}
+
+	return ""
+}
+
+// budgetUsed counts the user's upvotes in the current period on open suggestions other than excludeCardID
+func (s *Store) budgetUsed(userID, excludeCardID int64, since time.Time) int {
+	used := 0
+	for key, v := range s.votes {
+		if key.userID != userID || key.cardID == excludeCardID || v.Value != 1 || v.CreatedAt.Before(since) {
+			continue
+		}
+		if c := s.cards[key.cardID]; c != nil && c.Type == "suggestion" && c.Status != "closed" && c.Status != "fixed" {
+			used++
+		}
+	}
+	return used
+}
+
+// GetVoteBudgetUsed returns how many suggestion upvotes the user spent since the start of the period
+func (s *Store) GetVoteBudgetUsed(ctx context.Context, userID int64, since time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.budgetUsed(userID, 0, since), nil
+}
+
+// ToggleVote applies a vote: voting the same value twice removes the vote. Votes matching
+// rules are stored as flagged; voided votes stay voided when changed. Upvotes on suggestions
+// are checked against budget and ErrVoteBudgetExceeded is returned when it is spent.
+// Returns nil if the card does not exist.
+func (s *Store) ToggleVote(ctx context.Context, userID, cardID int64, value int, rules repository.VoteRules, budget repository.VoteBudget) (*models.VoteResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.cards[cardID]
+	if c == nil {
+		return nil, nil
+	}
+
+	key := voteKey{userID, cardID}
+	old := s.votes[key]
+	oldValue := 0
+	if old != nil {
+		oldValue = old.Value
+	}
+
+	newValue := value
+	if oldValue == value {
+		newValue = 0
+	}
+
+	var remaining *int
+	if budget.Limit > 0 && c.Type == "suggestion" {
+		used := s.budgetUsed(userID, cardID, budget.Since)
+		if newValue == 1 && c.Status != "closed" && c.Status != "fixed" {
+			if oldValue != 1 && used >= budget.Limit {
+				return nil, repository.ErrVoteBudgetExceeded
+			}
+			used++
+		}
+		left := budget.Limit - used
+		if left < 0 {
+			left = 0
+		}
+		remaining = &left
+	}
+
+	if newValue == 0 {
+		delete(s.votes, key)
+	} else {
+		if s.users[userID] == nil {
+			return nil, ErrForeignKey
+		}
+		status, flagReason := repository.VoteValid, ""
+		if reason := s.checkVote(rules, userID, cardID); reason != "" {
+			status, flagReason = repository.VoteFlagged, reason
+		}
+		if old != nil && old.Status == repository.VoteVoided {
+			status, flagReason = old.Status, old.FlagReason
+		}
+		s.votes[key] = &models.Vote{
+			UserID:     userID,
+			CardID:     cardID,
+			Value:      newValue,
+			Status:     status,
+			FlagReason: flagReason,
+			CreatedAt:  now(),
+		}
+	}
+
+	s.refreshCardVotes(c)
+	return &models.VoteResult{
+		CardID:         cardID,
+		Value:          newValue,
+		Rating:         c.Rating,
+		Likes:          c.Likes,
+		Dislikes:       c.Dislikes,
+		RemainingVotes: remaining,
+	}, nil
+}
+
+// refreshCardVotes recomputes the vote counters, rating and ranking scores of a card from its votes
+func (s *Store) refreshCardVotes(c *card) {
+	likes, dislikes := 0, 0
+	for key, v := range s.votes {
+		if key.cardID != c.ID || v.Status != repository.VoteValid {
+			continue
+		}
+		if v.Value == 1 {
+			likes++
+		} else if v.Value == -1 {
+			dislikes++
+		}
+	}
+	c.Likes, c.Dislikes, c.Rating = likes, dislikes, likes-dislikes
+	c.hotScore = hotScore(likes, dislikes, c.CreatedAt)
+	c.bestScore = bestScore(likes, dislikes)
+	c.controversyScore = controversyScore(likes, dislikes)
+	s.cardChanged(c)
+}
+
+// ReconcileCards recomputes the vote and comment counters, rating and ranking scores
+// of every card that has drifted, and returns the number of cards fixed.
+func (s *Store) ReconcileCards(ctx context.Context) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var fixed int64
+	version := s.cardsVersion
+	for _, c := range s.cards {
+		before := *c
+		s.refreshCardVotes(c)
+		c.commentCount, c.internalCommentCount = 0, 0
+		for _, cm := range s.comments {
+			if cm.CardID == c.ID {
+				s.countComment(c, cm.IsInternal, 1)
+			}
+		}
+		if c.Likes != before.Likes || c.Dislikes != before.Dislikes || c.Rating != before.Rating ||
+			c.commentCount != before.commentCount || c.internalCommentCount != before.internalCommentCount ||
+			c.hotScore != before.hotScore || c.bestScore != before.bestScore || c.controversyScore != before.controversyScore {
+			fixed++
+		} else {
+			c.updatedAt = before.updatedAt
+		}
+	}
+	if fixed == 0 {
+		s.cardsVersion = version
+	}
+	return fixed, nil
+}
+
+// ListCardVotes returns the voters of a card, newest first. Flagged and voided
+// votes are only included when includeUnverified is set.
+func (s *Store) ListCardVotes(ctx context.Context, cardID int64, includeUnverified bool, limit, offset int) ([]*models.Vote, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matched := s.listVotes(func(v *models.Vote) bool {
+		return v.CardID == cardID && (v.Status == repository.VoteValid || includeUnverified)
+	})
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].CreatedAt.After(matched[j].CreatedAt)
+		}
+		return matched[i].UserID < matched[j].UserID
+	})
+
+	var votes []*models.Vote
+	for _, v := range page(matched, limit, offset) {
+		author := s.author(v.UserID)
+		if author == nil {
+			continue
+		}
+		row := *v
+		row.User = author
+		if !includeUnverified {
+			row.Status, row.FlagReason = "", ""
+		}
+		votes = append(votes, &row)
+	}
+	return votes, len(matched), nil
+}
+
+// ListReviewVotes returns votes with the given status (flagged or voided), newest first
+func (s *Store) ListReviewVotes(ctx context.Context, status string, limit, offset int) ([]*models.Vote, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matched := s.listVotes(func(v *models.Vote) bool { return v.Status == status })
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].CreatedAt.After(matched[j].CreatedAt) })
+
+	var votes []*models.Vote
+	for _, v := range page(matched, limit, offset) {
+		author, c := s.author(v.UserID), s.cards[v.CardID]
+		if author == nil || c == nil {
+			continue
+		}
+		row := *v
+		row.User = author
+		row.CardTitle = c.Title
+		votes = append(votes, &row)
+	}
+	return votes, len(matched), nil
+}
+
+// listVotes returns the votes matching keep ordered by card and user, for a stable base order
+func (s *Store) listVotes(keep func(v *models.Vote) bool) []*models.Vote {
+	var votes []*models.Vote
+	for _, v := range s.votes {
+		if keep(v) {
+			votes = append(votes, v)
+		}
+	}
+	sort.Slice(votes, func(i, j int) bool {
+		if votes[i].CardID != votes[j].CardID {
+			return votes[i].CardID < votes[j].CardID
+		}
+		return votes[i].UserID < votes[j].UserID
+	})
+	return votes
+}
+
+// page applies LIMIT and OFFSET to rows
+func page[T any](rows []T, limit, offset int) []T {
+	if offset >= len(rows) {
+		return nil
+	}
+	rows = rows[offset:]
+	if limit < len(rows) {
+		rows = rows[:limit]
+	}
+	return rows
+}
+
+// SetVoteStatus approves (valid) or voids a vote and recomputes the card's rating.
+// Returns false if the vote does not exist.
+func (s *Store) SetVoteStatus(ctx context.Context, userID, cardID int64, status string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, v := s.cards[cardID], s.votes[voteKey{userID, cardID}]
+	if c == nil || v == nil {
+		return false, nil
+	}
+	v.Status = status
+	s.refreshCardVotes(c)
+	return true, nil
+}
+
+func (s *Store) GetUserVote(ctx context.Context, userID, cardID int64) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if v := s.votes[voteKey{userID, cardID}]; v != nil {
+		return v.Value, nil
+	}
+	return 0, nil
+}
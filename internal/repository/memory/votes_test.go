@@ -0,0 +1,44 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bugtracker/internal/models"
+	"bugtracker/internal/repository"
+)
+
+func TestReconcileRepairsScores(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	if err := s.UpsertUser(ctx, &models.User{ID: 1001, FirstName: "Alice", AuthDate: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	card := &models.Card{UserID: 1001, Title: "Crash on start", Type: "issue", Status: "open"}
+	if err := s.CreateCard(ctx, card, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ToggleVote(ctx, 1001, card.ID, 1, repository.VoteRules{}, repository.VoteBudget{}); err != nil {
+		t.Fatal(err)
+	}
+	if fixed, err := s.ReconcileCards(ctx); fixed != 0 || err != nil {
+		t.Fatalf("ReconcileCards on a consistent card = %d, %v", fixed, err)
+	}
+
+	want := *s.cards[card.ID]
+	for name, score := range map[string]*float64{
+		"hot":         &s.cards[card.ID].hotScore,
+		"best":        &s.cards[card.ID].bestScore,
+		"controversy": &s.cards[card.ID].controversyScore,
+	} {
+		*score = 42
+		if fixed, err := s.ReconcileCards(ctx); fixed != 1 || err != nil {
+			t.Errorf("ReconcileCards with a drifted %s score = %d, %v", name, fixed, err)
+		}
+		got := s.cards[card.ID]
+		if got.hotScore != want.hotScore || got.bestScore != want.bestScore || got.controversyScore != want.controversyScore {
+			t.Errorf("scores after reconciling the %s score = %v, %v, %v", name, got.hotScore, got.bestScore, got.controversyScore)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"bugtracker/internal/models"
+)
+
+// Notifications are written to the notification_outbox table in the transaction of the
+// change they announce (CreateCard, CreateComment, UpdateCardStatus), so a change is
+// never stored without them. A worker claims the due ones, delivers them and deletes
+// them; failed deliveries are rescheduled and finally moved to the dead letters.
+
+// Notification states
+const (
+	NotificationPending = "pending"
+	NotificationDead    = "dead"
+)
+
+// enqueueNotifications writes notifications to the outbox as pending and due now
+func enqueueNotifications(ctx context.Context, tx *sql.Tx, notify []*models.Notification) error {
+	for _, n := range notify {
+		var commentRef, actorRef, statusRef interface{}
+		if n.CommentID != 0 {
+			commentRef = n.CommentID
+		}
+		if n.ActorID != 0 {
+			actorRef = n.ActorID
+		}
+		if n.CardStatus != "" {
+			statusRef = n.CardStatus
+		}
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO notification_outbox (user_id, event, card_id, comment_id, actor_id, card_status)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, state, next_attempt_at, created_at
+		`, n.UserID, n.Event, n.CardID, commentRef, actorRef, statusRef).Scan(&n.ID, &n.State, &n.NextAttemptAt, &n.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const notificationColumns = `o.id, o.user_id, o.event, o.card_id, COALESCE(o.comment_id, 0), COALESCE(o.actor_id, 0),
+	COALESCE(o.card_status, ''), o.state, o.attempts, o.next_attempt_at, o.last_error, o.created_at`
+
+func scanNotification(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*models.Notification, error) {
+	n := &models.Notification{}
+	err := row.Scan(append([]interface{}{
+		&n.ID, &n.UserID, &n.Event, &n.CardID, &n.CommentID, &n.ActorID,
+		&n.CardStatus, &n.State, &n.Attempts, &n.NextAttemptAt, &n.LastError, &n.CreatedAt,
+	}, extra...)...)
+	return n, err
+}
+
+// ClaimNotifications takes up to limit pending notifications that are due, oldest first,
+// and counts an attempt for each. They are not handed out again until lease has passed,
+// so one whose delivery is never finished, as when the process stops, is retried then.
+// Notifications claimed by another worker are skipped.
+func (r *Repository) ClaimNotifications(ctx context.Context, limit int, lease time.Duration) ([]*models.Notification, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		UPDATE notification_outbox o SET
+			attempts = o.attempts + 1,
+			next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM (
+			SELECT id FROM notification_outbox
+			WHERE state = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		) due
+		WHERE o.id = due.id
+		RETURNING `+notificationColumns,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batch []*models.Notification
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		batch = append(batch, n)
+	}
+	return batch, rows.Err()
+}
+
+// DeleteNotification removes a delivered notification from the outbox
+func (r *Repository) DeleteNotification(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM notification_outbox WHERE id = $1", id)
+	return err
+}
+
+// FailNotification records a failed delivery and schedules the next attempt at retryAt.
+// A zero retryAt moves the notification to the dead letters.
+func (r *Repository) FailNotification(ctx context.Context, id int64, lastError string, retryAt time.Time) error {
+	if retryAt.IsZero() {
+		_, err := r.db.ExecContext(ctx, "UPDATE notification_outbox SET state = 'dead', last_error = $2 WHERE id = $1", id, lastError)
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "UPDATE notification_outbox SET last_error = $2, next_attempt_at = $3 WHERE id = $1", id, lastError, retryAt)
+	return err
+}
+
+// DeferNotification puts off a claimed notification until a given time without counting
+// the attempt, as when it is due during the recipient's quiet hours
+func (r *Repository) DeferNotification(ctx context.Context, id int64, until time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE notification_outbox SET attempts = GREATEST(attempts - 1, 0), next_attempt_at = $2
+		WHERE id = $1
+	`, id, until)
+	return err
+}
+
+// ListNotifications returns the notifications in a state, newest first
+func (r *Repository) ListNotifications(ctx context.Context, state string, limit, offset int) ([]*models.Notification, int, error) {
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM notification_outbox WHERE state = $1", state).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+notificationColumns+`, c.title
+		FROM notification_outbox o
+		JOIN cards c ON o.card_id = c.id
+		WHERE o.state = $1
+		ORDER BY o.created_at DESC, o.id DESC
+		LIMIT $2 OFFSET $3
+	`, state, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var notifications []*models.Notification
+	for rows.Next() {
+		var title string
+		n, err := scanNotification(rows, &title)
+		if err != nil {
+			return nil, 0, err
+		}
+		n.CardTitle = title
+		notifications = append(notifications, n)
+	}
+	return notifications, total, rows.Err()
+}
+
+// RetryNotification puts a dead notification back in the queue with its attempts reset.
+// Returns false if there is no dead notification with that ID.
+func (r *Repository) RetryNotification(ctx context.Context, id int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE notification_outbox SET state = 'pending', attempts = 0, next_attempt_at = NOW()
+		WHERE id = $1 AND state = 'dead'
+	`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// RetryDeadNotifications puts every dead notification back in the queue and returns how many
+func (r *Repository) RetryDeadNotifications(ctx context.Context) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE notification_outbox SET state = 'pending', attempts = 0, next_attempt_at = NOW()
+		WHERE state = 'dead'
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+
+	"bugtracker/internal/models"
+)
+
+// GetNotificationPreferences returns nil if the user never changed their preferences
+func (r *Repository) GetNotificationPreferences(ctx context.Context, userID int64) (*models.NotificationPreferences, error) {
+	p := &models.NotificationPreferences{UserID: userID}
+	err := r.reader(ctx).QueryRowContext(ctx, `
+		SELECT timezone, COALESCE(to_char(quiet_start, 'HH24:MI'), ''), COALESCE(to_char(quiet_end, 'HH24:MI'), ''), muted
+		FROM notification_preferences WHERE user_id = $1
+	`, userID).Scan(&p.Timezone, &p.QuietStart, &p.QuietEnd, pq.Array(&p.Muted))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *Repository) SaveNotificationPreferences(ctx context.Context, p *models.NotificationPreferences) error {
+	muted := p.Muted
+	if muted == nil {
+		muted = []string{}
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO notification_preferences (user_id, timezone, quiet_start, quiet_end, muted)
+		VALUES ($1, $2, NULLIF($3, '')::time, NULLIF($4, '')::time, $5)
+		ON CONFLICT (user_id) DO UPDATE SET
+			timezone = EXCLUDED.timezone,
+			quiet_start = EXCLUDED.quiet_start,
+			quiet_end = EXCLUDED.quiet_end,
+			muted = EXCLUDED.muted,
+			updated_at = NOW()
+	`, p.UserID, p.Timezone, p.QuietStart, p.QuietEnd, pq.Array(muted))
+	return err
+}
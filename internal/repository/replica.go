@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+// A Repository can send read-only queries to a streaming replica of the database:
+// card lists and counts, search, comments, votes, reactions, mentions, saved view
+// lists and shared views. Writes, transactions that write, and lookups that gate a write (users,
+// single comments and saved views) always use the primary.
+//
+// Replicas lag behind. Callers that must see a recent write, their own or the one
+// they follow, read under a context from Primary.
+//
+// While the replica does not answer, reads go to the primary: CheckReplica and
+// WatchReplica ping it and switch reads back once it answers again.
+
+// NewWithReplica returns a Repository reading from replica where it can.
+// A nil replica makes it the same as New(db).
+func NewWithReplica(db, replica *sql.DB) *Repository {
+	return &Repository{db: db, replica: replica}
+}
+
+type primaryKey struct{}
+
+// Primary returns a context under which every repository read goes to the primary
+func Primary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// IsPrimary reports whether ctx comes from Primary
+func IsPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
+
+// reader returns the database for a read-only query under ctx
+func (r *Repository) reader(ctx context.Context) *sql.DB {
+	if r.replica == nil || IsPrimary(ctx) || r.replicaDown.Load() {
+		return r.db
+	}
+	return r.replica
+}
+
+// CheckReplica pings the replica and sends reads to the primary if it fails, or back to
+// the replica if it answers. Changes are logged. It does nothing without a replica.
+func (r *Repository) CheckReplica(ctx context.Context) error {
+	if r.replica == nil {
+		return nil
+	}
+	err := r.replica.PingContext(ctx)
+	if ctx.Err() != nil {
+		return err
+	}
+	down := err != nil
+	if r.replicaDown.Swap(down) != down {
+		if down {
+			log.Printf("Replica database unavailable, reading from the primary: %v", err)
+		} else {
+			log.Println("Replica database available again, reading from it")
+		}
+	}
+	return err
+}
+
+// WatchReplica runs CheckReplica every interval until ctx is done
+func (r *Repository) WatchReplica(ctx context.Context, interval time.Duration) {
+	if r.replica == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.CheckReplica(ctx)
+		}
+	}
+}
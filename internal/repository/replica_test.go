@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	// sql.Open only validates its arguments; nothing is connected
+	primary, _ := sql.Open("postgres", "host=primary")
+	replica, _ := sql.Open("postgres", "host=replica")
+	ctx := context.Background()
+
+	if New(primary).reader(ctx) != primary {
+		t.Error("without a replica reads must use the primary")
+	}
+	r := NewWithReplica(primary, replica)
+	if r.reader(ctx) != replica {
+		t.Error("reads must use the replica")
+	}
+	if r.reader(Primary(ctx)) != primary {
+		t.Error("reads under Primary must use the primary")
+	}
+}
+
+func TestReaderFallsBackToPrimary(t *testing.T) {
+	primary, _ := sql.Open("postgres", "host=primary")
+	// Nothing listens on port 1, so connecting fails at once
+	replica, _ := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1")
+	defer replica.Close()
+	r := NewWithReplica(primary, replica)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.CheckReplica(canceled)
+	if r.reader(context.Background()) != replica {
+		t.Error("a canceled check must not mark the replica down")
+	}
+
+	if err := r.CheckReplica(context.Background()); err == nil {
+		t.Fatal("CheckReplica succeeded without a replica listening")
+	}
+	if r.reader(context.Background()) != primary {
+		t.Error("reads must use the primary while the replica is down")
+	}
+}
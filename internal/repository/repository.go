@@ -1,8 +1,13 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/lib/pq"
@@ -11,16 +16,43 @@ import (
 )
 
 type Repository struct {
-	db *sql.DB
+	db          *sql.DB
+	replica     *sql.DB     // nil without a replica
+	replicaDown atomic.Bool // set while the replica does not answer (see CheckReplica)
+}
+
+// queryRower is implemented by both *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
 
 func New(db *sql.DB) *Repository {
 	return &Repository{db: db}
 }
 
+// ErrTimeout is reported by Interruption
+var ErrTimeout = errors.New("query timed out")
+
+// Interruption tells whether err from a repository call means the query was cut short
+// rather than failed: ErrTimeout if the deadline of ctx passed or PostgreSQL's
+// statement_timeout fired. It returns nil for any other error.
+func Interruption(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "57014" { // query_canceled
+		return ErrTimeout
+	}
+	return nil
+}
+
 // User operations
-func (r *Repository) UpsertUser(u *models.User) error {
-	_, err := r.db.Exec(`
+func (r *Repository) UpsertUser(ctx context.Context, u *models.User) error {
+	_, err := r.db.ExecContext(ctx, `
 		INSERT INTO users (id, first_name, last_name, username, photo_url, auth_date, is_admin)
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		ON CONFLICT(id) DO UPDATE SET
@@ -33,9 +65,9 @@ func (r *Repository) UpsertUser(u *models.User) error {
 	return err
 }
 
-func (r *Repository) GetUser(id int64) (*models.User, error) {
+func (r *Repository) GetUser(ctx context.Context, id int64) (*models.User, error) {
 	u := &models.User{}
-	err := r.db.QueryRow(`
+	err := r.db.QueryRowContext(ctx, `
 		SELECT id, first_name, COALESCE(last_name, ''), COALESCE(username, ''), COALESCE(photo_url, ''), auth_date, is_admin
 		FROM users WHERE id = $1
 	`, id).Scan(&u.ID, &u.FirstName, &u.LastName, &u.Username, &u.PhotoURL, &u.AuthDate, &u.IsAdmin)
@@ -46,30 +78,57 @@ func (r *Repository) GetUser(id int64) (*models.User, error) {
 }
 
 // Card operations
-func (r *Repository) CreateCard(c *models.Card) error {
-	err := r.db.QueryRow(`
-		INSERT INTO cards (user_id, title, description, type, status, images, rating, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, 0, $7)
+
+// CreateCard creates a card with the mentions in its description and the notifications
+// about it, whose CardID is set to the new card's
+func (r *Repository) CreateCard(ctx context.Context, c *models.Card, mentioned []int64, notify []*models.Notification) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO cards (user_id, title, description, type, status, images, rating, hot_score, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, 0, card_hot_score(0, 0, $7), $7)
 		RETURNING id
 	`, c.UserID, c.Title, c.Description, c.Type, c.Status, pq.Array(c.Images), time.Now()).Scan(&c.ID)
-	return err
+	if err != nil {
+		return err
+	}
+
+	if err := createMentions(ctx, tx, c.ID, 0, c.UserID, mentioned); err != nil {
+		return err
+	}
+	for _, n := range notify {
+		n.CardID = c.ID
+	}
+	if err := enqueueNotifications(ctx, tx, notify); err != nil {
+		return err
+	}
+	if err := bumpCardsVersion(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (r *Repository) GetCard(id int64) (*models.Card, error) {
+// GetCard returns a card by ID. Internal comments are only counted when includeInternal is set.
+func (r *Repository) GetCard(ctx context.Context, id int64, includeInternal bool) (*models.Card, error) {
 	c := &models.Card{Author: &models.User{}}
-	err := r.db.QueryRow(`
+	err := r.reader(ctx).QueryRowContext(ctx, `
 		SELECT c.id, c.user_id, c.title, COALESCE(c.description, ''), c.type, c.status, COALESCE(c.images, '{}'), c.rating, c.created_at,
 		       u.id, u.first_name, COALESCE(u.last_name, ''), COALESCE(u.username, ''), COALESCE(u.photo_url, ''),
-		       (SELECT COUNT(*) FROM comments WHERE card_id = c.id),
-		       (SELECT COUNT(*) FROM votes WHERE card_id = c.id AND value = 1),
-		       (SELECT COUNT(*) FROM votes WHERE card_id = c.id AND value = -1)
+		       c.comment_count + CASE WHEN $2 THEN c.internal_comment_count ELSE 0 END,
+		       c.likes, c.dislikes,
+		       EXISTS(SELECT 1 FROM comments WHERE card_id = c.id AND is_official)
 		FROM cards c
 		JOIN users u ON c.user_id = u.id
 		WHERE c.id = $1
-	`, id).Scan(
+	`, id, includeInternal).Scan(
 		&c.ID, &c.UserID, &c.Title, &c.Description, &c.Type, &c.Status, pq.Array(&c.Images), &c.Rating, &c.CreatedAt,
 		&c.Author.ID, &c.Author.FirstName, &c.Author.LastName, &c.Author.Username, &c.Author.PhotoURL,
-		&c.CommentCount, &c.Likes, &c.Dislikes,
+		&c.CommentCount, &c.Likes, &c.Dislikes, &c.HasOfficialResponse,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -77,196 +136,699 @@ func (r *Repository) GetCard(id int64) (*models.Card, error) {
 	return c, err
 }
 
-func (r *Repository) ListCards(sort, cardType, status string, limit, offset int, userID int64) ([]*models.Card, int, error) {
-	baseQuery := `
-		SELECT c.id, c.user_id, c.title, COALESCE(c.description, ''), c.type, c.status, COALESCE(c.images, '{}'), c.rating, c.created_at,
-		       u.id, u.first_name, COALESCE(u.last_name, ''), COALESCE(u.username, ''), COALESCE(u.photo_url, ''),
-		       (SELECT COUNT(*) FROM comments WHERE card_id = c.id),
-		       COALESCE((SELECT value FROM votes WHERE card_id = c.id AND user_id = $1), 0),
-		       (SELECT COUNT(*) FROM votes WHERE card_id = c.id AND value = 1),
-		       (SELECT COUNT(*) FROM votes WHERE card_id = c.id AND value = -1)
-		FROM cards c
-		JOIN users u ON c.user_id = u.id
-		WHERE 1=1
-	`
-	countQuery := `SELECT COUNT(*) FROM cards WHERE 1=1`
-	args := []interface{}{userID}
-	countArgs := []interface{}{}
-	argNum := 2
-
-	if cardType != "" {
-		baseQuery += " AND c.type = $" + itoa(argNum)
-		countQuery += " AND type = $" + itoa(len(countArgs)+1)
-		args = append(args, cardType)
-		countArgs = append(countArgs, cardType)
-		argNum++
-	}
-	if status != "" {
-		baseQuery += " AND c.status = $" + itoa(argNum)
-		countQuery += " AND status = $" + itoa(len(countArgs)+1)
-		args = append(args, status)
-		countArgs = append(countArgs, status)
-		argNum++
-	}
-
-	switch sort {
-	case "time":
-		baseQuery += " ORDER BY c.created_at DESC"
-	default:
-		baseQuery += " ORDER BY c.rating DESC, c.created_at DESC"
-	}
+func itoa(i int) string {
+	return strconv.Itoa(i)
+}
 
-	baseQuery += " LIMIT $" + itoa(argNum) + " OFFSET $" + itoa(argNum+1)
-	args = append(args, limit, offset)
+// ListCards lists cards matching the filter. A non-empty Query is a full-text search;
+// matching cards get TitleHighlight and Snippet, and the "relevance" sort becomes available.
+// next is the cursor of the following page, nil on the last page.
+func (r *Repository) ListCards(ctx context.Context, filter CardFilter, sort string, page CardPage, userID int64, includeInternal bool) (cards []*models.Card, total int, next *CardCursor, err error) {
+	if page.After != nil && page.After.Sort != sort {
+		return nil, 0, nil, ErrInvalidCursor
+	}
 
-	var total int
-	r.db.QueryRow(countQuery, countArgs...).Scan(&total)
+	switch page.Total {
+	case TotalExact:
+		if total, err = r.CountCards(ctx, filter); err != nil {
+			return nil, 0, nil, err
+		}
+	case TotalEstimate:
+		if total, err = r.estimateCards(ctx, filter); err != nil {
+			return nil, 0, nil, err
+		}
+	default:
+		total = -1
+	}
 
-	rows, err := r.db.Query(baseQuery, args...)
+	query, args := listCardsSQL(filter, sort, page, userID, includeInternal)
+	rows, err := r.reader(ctx).QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 	defer rows.Close()
 
-	var cards []*models.Card
+	var lastKey string
 	for rows.Next() {
+		if len(cards) == page.Limit {
+			next = &CardCursor{Sort: sort, Key: lastKey, ID: cards[len(cards)-1].ID}
+			break
+		}
 		c := &models.Card{Author: &models.User{}}
+		var titleHighlight, snippet string
 		err := rows.Scan(
 			&c.ID, &c.UserID, &c.Title, &c.Description, &c.Type, &c.Status, pq.Array(&c.Images), &c.Rating, &c.CreatedAt,
 			&c.Author.ID, &c.Author.FirstName, &c.Author.LastName, &c.Author.Username, &c.Author.PhotoURL,
-			&c.CommentCount, &c.UserVote, &c.Likes, &c.Dislikes,
+			&c.CommentCount, &c.UserVote, &c.Likes, &c.Dislikes, &c.HasOfficialResponse,
+			&titleHighlight, &snippet, &lastKey,
 		)
 		if err != nil {
-			return nil, 0, err
+			return nil, 0, nil, err
+		}
+		if filter.Query != "" {
+			c.TitleHighlight = highlight(titleHighlight)
+			c.Snippet = highlight(snippet)
 		}
 		cards = append(cards, c)
 	}
-	return cards, total, nil
+	return cards, total, next, rows.Err()
 }
 
-func itoa(i int) string {
-	return strconv.Itoa(i)
+// CountCards returns the number of cards matching a filter
+func (r *Repository) CountCards(ctx context.Context, filter CardFilter) (int, error) {
+	query, args := countCardsSQL(filter)
+	var total int
+	err := r.reader(ctx).QueryRowContext(ctx, query, args...).Scan(&total)
+	return total, err
 }
 
-func (r *Repository) ListCardsWithSearch(sort, cardType, status, query string, limit, offset int, userID int64) ([]*models.Card, int, error) {
-	baseQuery := `
-		SELECT c.id, c.user_id, c.title, COALESCE(c.description, ''), c.type, c.status, COALESCE(c.images, '{}'), c.rating, c.created_at,
-		       u.id, u.first_name, COALESCE(u.last_name, ''), COALESCE(u.username, ''), COALESCE(u.photo_url, ''),
-		       (SELECT COUNT(*) FROM comments WHERE card_id = c.id),
-		       COALESCE((SELECT value FROM votes WHERE card_id = c.id AND user_id = $1), 0),
-		       (SELECT COUNT(*) FROM votes WHERE card_id = c.id AND value = 1),
-		       (SELECT COUNT(*) FROM votes WHERE card_id = c.id AND value = -1)
-		FROM cards c
-		JOIN users u ON c.user_id = u.id
-		WHERE 1=1
-	`
-	countQuery := `SELECT COUNT(*) FROM cards WHERE 1=1`
-	args := []interface{}{userID}
-	countArgs := []interface{}{}
-	argNum := 2
-
-	if query != "" {
-		baseQuery += " AND (c.title ILIKE '%' || $" + itoa(argNum) + " || '%' OR c.description ILIKE '%' || $" + itoa(argNum) + " || '%')"
-		countQuery += " AND (title ILIKE '%' || $" + itoa(len(countArgs)+1) + " || '%' OR description ILIKE '%' || $" + itoa(len(countArgs)+1) + " || '%')"
-		args = append(args, query)
-		countArgs = append(countArgs, query)
-		argNum++
-	}
-	if cardType != "" {
-		baseQuery += " AND c.type = $" + itoa(argNum)
-		countQuery += " AND type = $" + itoa(len(countArgs)+1)
-		args = append(args, cardType)
-		countArgs = append(countArgs, cardType)
-		argNum++
-	}
-	if status != "" {
-		baseQuery += " AND c.status = $" + itoa(argNum)
-		countQuery += " AND status = $" + itoa(len(countArgs)+1)
-		args = append(args, status)
-		countArgs = append(countArgs, status)
-		argNum++
-	}
-
-	switch sort {
-	case "time":
-		baseQuery += " ORDER BY c.created_at DESC"
-	default:
-		baseQuery += " ORDER BY c.rating DESC, c.created_at DESC"
+// CountCardsBatch counts the cards matching each of the filters in one query
+func (r *Repository) CountCardsBatch(ctx context.Context, filters []CardFilter) ([]int, error) {
+	counts := make([]int, len(filters))
+	if len(filters) == 0 {
+		return counts, nil
+	}
+	query, args := countCardsBatchSQL(filters)
+	dest := make([]interface{}, len(counts))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+	if err := r.reader(ctx).QueryRowContext(ctx, query, args...).Scan(dest...); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// estimateCards returns the planner's estimate of the number of cards matching a filter
+func (r *Repository) estimateCards(ctx context.Context, filter CardFilter) (int, error) {
+	query, args := estimateCardsSQL(filter)
+	var plan []byte
+	if err := r.reader(ctx).QueryRowContext(ctx, query, args...).Scan(&plan); err != nil {
+		return 0, err
 	}
+	var explain []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal(plan, &explain); err != nil || len(explain) == 0 {
+		return 0, err
+	}
+	return int(explain[0].Plan.Rows), nil
+}
 
-	baseQuery += " LIMIT $" + itoa(argNum) + " OFFSET $" + itoa(argNum+1)
-	args = append(args, limit, offset)
+// CardFacets counts the cards matching the filter per status, type and tag in one query
+// (see cardFacetsSQL)
+func (r *Repository) CardFacets(ctx context.Context, filter CardFilter) (*models.CardFacets, error) {
+	query, args := cardFacetsSQL(filter)
+	rows, err := r.reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	var total int
-	r.db.QueryRow(countQuery, countArgs...).Scan(&total)
+	facets := &models.CardFacets{
+		Statuses: []models.FacetCount{},
+		Types:    []models.FacetCount{},
+		Tags:     []models.FacetCount{},
+	}
+	for rows.Next() {
+		var facet string
+		var fc models.FacetCount
+		if err := rows.Scan(&facet, &fc.Value, &fc.Count); err != nil {
+			return nil, err
+		}
+		switch facet {
+		case "status":
+			facets.Statuses = append(facets.Statuses, fc)
+		case "type":
+			facets.Types = append(facets.Types, fc)
+		case "tag":
+			facets.Tags = append(facets.Tags, fc)
+		}
+	}
+	return facets, rows.Err()
+}
+
+// CardsVersion returns a token that changes whenever any card does: a card is added or
+// deleted, or one of them or anything shown with it (votes, comments, reactions, official
+// responses) changes. It reads the counter these changes advance (see bumpCardsVersion),
+// for answering conditional requests for card lists; every list changes with it,
+// whatever its filter. Changes to authors' profiles are not tracked.
+func (r *Repository) CardsVersion(ctx context.Context) (string, error) {
+	var version int64
+	if err := r.reader(ctx).QueryRowContext(ctx, "SELECT version FROM cards_version").Scan(&version); err != nil {
+		return "", err
+	}
+	return ListVersion(version), nil
+}
+
+// bumpCardsVersion advances the version of the card lists. It is the last statement of
+// a transaction that changed cards: the counter's row stays locked until the commit, so
+// the versions follow the commit order and a version is only seen with all changes up to it.
+func bumpCardsVersion(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, "UPDATE cards_version SET version = version + 1")
+	return err
+}
+
+// execCardChange runs a statement that changes cards and, when it changed any, advances
+// the version of the card lists with it. It returns the number of rows changed.
+func (r *Repository) execCardChange(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
 
-	rows, err := r.db.Query(baseQuery, args...)
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		if err := bumpCardsVersion(ctx, tx); err != nil {
+			return 0, err
+		}
+	}
+	return n, tx.Commit()
+}
+
+// CardVersion is CardsVersion for a single card; it returns "" if the card does not exist
+func (r *Repository) CardVersion(ctx context.Context, id int64) (string, error) {
+	var counters int64
+	var updated sql.NullTime
+	err := r.reader(ctx).QueryRowContext(ctx, `
+		SELECT updated_at, likes + dislikes + comment_count + internal_comment_count FROM cards WHERE id = $1
+	`, id).Scan(&updated, &counters)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return Version(1, updated.Time, counters), nil
+}
+
+// Version formats a version token from the number of cards, their latest updated_at
+// and the sum of their counters
+func Version(count int64, updated time.Time, counters int64) string {
+	return strconv.FormatInt(count, 36) + "." + strconv.FormatInt(updated.UnixMicro(), 36) + "." + strconv.FormatInt(counters, 36)
+}
+
+// ListVersion formats the version token of the card lists
+func ListVersion(version int64) string {
+	return strconv.FormatInt(version, 36)
+}
+
+// Search finds cards matching query by their own text or by their comments, best matches
+// first. Each result carries up to commentsPerCard best matching comments with excerpts.
+func (r *Repository) Search(ctx context.Context, query string, limit, offset, commentsPerCard int, includeInternal bool) ([]*models.SearchResult, int, error) {
+	sqlQuery, args := searchSQL(query, limit, offset, includeInternal)
+	rows, err := r.reader(ctx).QueryContext(ctx, sqlQuery, args...)
 	if err != nil {
 		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var cards []*models.Card
+	var results []*models.SearchResult
+	var total int
+	byCard := make(map[int64]*models.SearchResult)
+	var cardIDs []int64
 	for rows.Next() {
-		c := &models.Card{Author: &models.User{}}
-		err := rows.Scan(
-			&c.ID, &c.UserID, &c.Title, &c.Description, &c.Type, &c.Status, pq.Array(&c.Images), &c.Rating, &c.CreatedAt,
-			&c.Author.ID, &c.Author.FirstName, &c.Author.LastName, &c.Author.Username, &c.Author.PhotoURL,
-			&c.CommentCount, &c.UserVote, &c.Likes, &c.Dislikes,
-		)
+		res := &models.SearchResult{Comments: []*models.CommentMatch{}}
+		var title, snippet string
+		if err := rows.Scan(&res.CardID, &res.Type, &res.Status, &res.CardMatch, &res.CommentMatches, &title, &snippet, &total); err != nil {
+			return nil, 0, err
+		}
+		res.Title = highlight(title)
+		if res.CardMatch {
+			res.Snippet = highlight(snippet)
+		}
+		results = append(results, res)
+		byCard[res.CardID] = res
+		cardIDs = append(cardIDs, res.CardID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(cardIDs) == 0 || commentsPerCard <= 0 {
+		return results, total, nil
+	}
+
+	// Excerpts are only built for the comments that are returned
+	sqlQuery, args = searchCommentsSQL(query, cardIDs, commentsPerCard, includeInternal)
+	crows, err := r.reader(ctx).QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer crows.Close()
+
+	for crows.Next() {
+		m := &models.CommentMatch{Author: &models.User{}}
+		var cardID int64
+		var excerpt string
+		err := crows.Scan(&m.ID, &cardID, &m.IsInternal, &m.CreatedAt, &excerpt,
+			&m.Author.ID, &m.Author.FirstName, &m.Author.LastName, &m.Author.Username, &m.Author.PhotoURL)
 		if err != nil {
 			return nil, 0, err
 		}
+		m.Excerpt = highlight(excerpt)
+		if res := byCard[cardID]; res != nil {
+			res.Comments = append(res.Comments, m)
+		}
+	}
+	return results, total, crows.Err()
+}
+
+// SimilarCardsOptions configures FindSimilarCards. A card matches when its title
+// similarity reaches MinSimilarity or its full-text rank reaches MinRank.
+type SimilarCardsOptions struct {
+	Type          string // restrict to a card type, if set
+	MinSimilarity float64
+	MinRank       float64
+	Limit         int
+}
+
+// FindSimilarCards returns open cards whose title resembles title, best matches first
+func (r *Repository) FindSimilarCards(ctx context.Context, title string, opts SimilarCardsOptions) ([]*models.SimilarCard, error) {
+	tx, err := r.reader(ctx).BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// The % operator (and its index) use this threshold
+	_, err = tx.ExecContext(ctx, "SELECT set_config('pg_trgm.similarity_threshold', $1, true)", strconv.FormatFloat(opts.MinSimilarity, 'f', -1, 64))
+	if err != nil {
+		return nil, err
+	}
+
+	query, args := similarCardsSQL(title, opts)
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cards []*models.SimilarCard
+	for rows.Next() {
+		c := &models.SimilarCard{}
+		err := rows.Scan(&c.ID, &c.Title, &c.Type, &c.Status, &c.Rating, &c.Likes, &c.Dislikes, &c.Similarity, &c.Rank)
+		if err != nil {
+			return nil, err
+		}
 		cards = append(cards, c)
 	}
-	return cards, total, nil
+	return cards, rows.Err()
 }
 
-func (r *Repository) UpdateCardStatus(id int64, status string) error {
-	_, err := r.db.Exec("UPDATE cards SET status = $1, updated_at = NOW() WHERE id = $2", status, id)
-	return err
+// UpdateCardStatus changes the status of a card and queues the notifications about it
+func (r *Repository) UpdateCardStatus(ctx context.Context, id int64, status string, notify []*models.Notification) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE cards SET status = $1, updated_at = NOW() WHERE id = $2", status, id); err != nil {
+		return err
+	}
+	if err := enqueueNotifications(ctx, tx, notify); err != nil {
+		return err
+	}
+	if err := bumpCardsVersion(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // Vote operations
-func (r *Repository) Vote(userID, cardID int64, value int) error {
-	tx, err := r.db.Begin()
+
+// Vote statuses. Only valid votes count towards rating, likes, dislikes and ranking scores.
+const (
+	VoteValid   = "valid"
+	VoteFlagged = "flagged"
+	VoteVoided  = "voided"
+)
+
+// VoteRules are the heuristics used to flag suspicious votes. A zero value disables a check.
+type VoteRules struct {
+	// Votes from accounts younger than this are flagged
+	MinAccountAge time.Duration
+	// Accounts younger than NewAccountAge casting UserBurstLimit votes within UserBurstWindow are flagged
+	NewAccountAge   time.Duration
+	UserBurstLimit  int
+	UserBurstWindow time.Duration
+	// Votes landing on a card that already got CardBurstLimit votes within CardBurstWindow are flagged
+	CardBurstLimit  int
+	CardBurstWindow time.Duration
+}
+
+// checkVote returns the reason a new vote looks suspicious, or an empty string
+func (rules VoteRules) checkVote(ctx context.Context, tx *sql.Tx, userID, cardID int64) (string, error) {
+	var createdAt sql.NullTime
+	err := tx.QueryRowContext(ctx, "SELECT created_at FROM users WHERE id = $1", userID).Scan(&createdAt)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+	accountAge := time.Duration(0)
+	if createdAt.Valid {
+		accountAge = time.Since(createdAt.Time)
+	}
+
+	if rules.MinAccountAge > 0 && accountAge < rules.MinAccountAge {
+		return "new_account", nil
+	}
+
+	if rules.UserBurstLimit > 0 && accountAge < rules.NewAccountAge {
+		var recent int
+		err := tx.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM votes WHERE user_id = $1 AND created_at > NOW() - make_interval(secs => $2)",
+			userID, rules.UserBurstWindow.Seconds(),
+		).Scan(&recent)
+		if err != nil {
+			return "", err
+		}
+		if recent >= rules.UserBurstLimit {
+			return "user_burst", nil
+		}
+	}
+
+	if rules.CardBurstLimit > 0 {
+		var recent int
+		err := tx.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM votes WHERE card_id = $1 AND user_id <> $2 AND created_at > NOW() - make_interval(secs => $3)",
+			cardID, userID, rules.CardBurstWindow.Seconds(),
+		).Scan(&recent)
+		if err != nil {
+			return "", err
+		}
+		if recent >= rules.CardBurstLimit {
+			return "card_burst", nil
+		}
+	}
+
+	return "", nil
+}
+
+// ErrVoteBudgetExceeded is returned by ToggleVote when the user has no votes left in the budget
+var ErrVoteBudgetExceeded = errors.New("vote budget exceeded")
+
+// VoteBudget limits how many upvotes a user can give to suggestions per period.
+// Upvotes on closed or fixed suggestions are returned to the budget. Limit 0 disables it.
+type VoteBudget struct {
+	Limit int
+	Since time.Time // start of the current period
+}
+
+// budgetUsed counts the user's upvotes in the current period on open suggestions other than excludeCardID
+func budgetUsed(ctx context.Context, q queryRower, userID, excludeCardID int64, since time.Time) (int, error) {
+	var used int
+	err := q.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM votes v
+		JOIN cards c ON v.card_id = c.id
+		WHERE v.user_id = $1 AND v.card_id <> $2 AND v.value = 1 AND v.created_at >= $3
+		  AND c.type = 'suggestion' AND c.status NOT IN ('closed', 'fixed')
+	`, userID, excludeCardID, since).Scan(&used)
+	return used, err
+}
+
+// GetVoteBudgetUsed returns how many suggestion upvotes the user spent since the start of the period
+func (r *Repository) GetVoteBudgetUsed(ctx context.Context, userID int64, since time.Time) (int, error) {
+	return budgetUsed(ctx, r.reader(ctx), userID, 0, since)
+}
+
+// ToggleVote applies a vote atomically: voting the same value twice removes the vote.
+// The card row is locked for the duration, so concurrent votes on a card are serialized
+// and rating always matches the votes table. Votes matching rules are stored as flagged;
+// voided votes stay voided when changed. Upvotes on suggestions are checked against budget
+// and ErrVoteBudgetExceeded is returned when it is spent. Returns nil if the card does not exist.
+func (r *Repository) ToggleVote(ctx context.Context, userID, cardID int64, value int, rules VoteRules, budget VoteBudget) (*models.VoteResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer tx.Rollback()
 
-	var oldValue int
-	err = tx.QueryRow("SELECT value FROM votes WHERE user_id = $1 AND card_id = $2", userID, cardID).Scan(&oldValue)
+	var cardType, cardStatus string
+	err = tx.QueryRowContext(ctx, "SELECT type, status FROM cards WHERE id = $1 FOR UPDATE", cardID).Scan(&cardType, &cardStatus)
 	if err == sql.ErrNoRows {
-		oldValue = 0
+		return nil, nil
 	} else if err != nil {
-		return err
+		return nil, err
 	}
 
-	if value == 0 {
-		_, err = tx.Exec("DELETE FROM votes WHERE user_id = $1 AND card_id = $2", userID, cardID)
+	var oldValue int
+	err = tx.QueryRowContext(ctx, "SELECT value FROM votes WHERE user_id = $1 AND card_id = $2", userID, cardID).Scan(&oldValue)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	newValue := value
+	if oldValue == value {
+		newValue = 0
+	}
+
+	var remaining *int
+	if budget.Limit > 0 && cardType == "suggestion" {
+		// Lock the user so concurrent votes on different cards can't overspend the budget
+		if _, err := tx.ExecContext(ctx, "SELECT id FROM users WHERE id = $1 FOR UPDATE", userID); err != nil {
+			return nil, err
+		}
+		used, err := budgetUsed(ctx, tx, userID, cardID, budget.Since)
+		if err != nil {
+			return nil, err
+		}
+		if newValue == 1 && cardStatus != "closed" && cardStatus != "fixed" {
+			if oldValue != 1 && used >= budget.Limit {
+				return nil, ErrVoteBudgetExceeded
+			}
+			used++
+		}
+		left := budget.Limit - used
+		if left < 0 {
+			left = 0
+		}
+		remaining = &left
+	}
+
+	if newValue == 0 {
+		_, err = tx.ExecContext(ctx, "DELETE FROM votes WHERE user_id = $1 AND card_id = $2", userID, cardID)
 	} else {
-		_, err = tx.Exec(`
-			INSERT INTO votes (user_id, card_id, value) VALUES ($1, $2, $3)
-			ON CONFLICT(user_id, card_id) DO UPDATE SET value = EXCLUDED.value
-		`, userID, cardID, value)
+		var reason string
+		reason, err = rules.checkVote(ctx, tx, userID, cardID)
+		if err != nil {
+			return nil, err
+		}
+		status, flagReason := VoteValid, sql.NullString{}
+		if reason != "" {
+			status, flagReason = VoteFlagged, sql.NullString{String: reason, Valid: true}
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO votes (user_id, card_id, value, status, flag_reason) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT(user_id, card_id) DO UPDATE SET
+				value = EXCLUDED.value,
+				created_at = NOW(),
+				status = CASE WHEN votes.status = 'voided' THEN votes.status ELSE EXCLUDED.status END,
+				flag_reason = CASE WHEN votes.status = 'voided' THEN votes.flag_reason ELSE EXCLUDED.flag_reason END
+		`, userID, cardID, newValue, status, flagReason)
 	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	diff := value - oldValue
-	_, err = tx.Exec("UPDATE cards SET rating = rating + $1 WHERE id = $2", diff, cardID)
+	result := &models.VoteResult{CardID: cardID, Value: newValue, RemainingVotes: remaining}
+	result.Rating, result.Likes, result.Dislikes, err = refreshCardVotes(ctx, tx, cardID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := bumpCardsVersion(ctx, tx); err != nil {
+		return nil, err
 	}
 
-	return tx.Commit()
+	return result, tx.Commit()
+}
+
+// refreshCardVotes recomputes the vote counters, rating and ranking scores of a card from its votes
+func refreshCardVotes(ctx context.Context, tx *sql.Tx, cardID int64) (rating, likes, dislikes int, err error) {
+	err = tx.QueryRowContext(ctx, `
+		UPDATE cards c SET
+			likes = v.likes,
+			dislikes = v.dislikes,
+			rating = v.likes - v.dislikes,
+			hot_score = card_hot_score(v.likes, v.dislikes, c.created_at),
+			best_score = card_best_score(v.likes, v.dislikes),
+			controversy_score = card_controversy_score(v.likes, v.dislikes),
+			updated_at = NOW()
+		FROM (
+			SELECT COUNT(*) FILTER (WHERE value = 1) AS likes,
+			       COUNT(*) FILTER (WHERE value = -1) AS dislikes
+			FROM votes WHERE card_id = $1 AND status = 'valid'
+		) v
+		WHERE c.id = $1
+		RETURNING c.rating, v.likes, v.dislikes
+	`, cardID).Scan(&rating, &likes, &dislikes)
+	return rating, likes, dislikes, err
+}
+
+// ReconcileCards recomputes the vote and comment counters, rating and ranking scores
+// from the votes and comments tables for every card that has drifted, and returns
+// the number of cards fixed.
+func (r *Repository) ReconcileCards(ctx context.Context) (int64, error) {
+	return r.execCardChange(ctx, `
+		UPDATE cards c SET
+			likes = v.likes,
+			dislikes = v.dislikes,
+			rating = v.likes - v.dislikes,
+			hot_score = card_hot_score(v.likes, v.dislikes, c.created_at),
+			best_score = card_best_score(v.likes, v.dislikes),
+			controversy_score = card_controversy_score(v.likes, v.dislikes),
+			comment_count = v.comments,
+			internal_comment_count = v.internal_comments,
+			updated_at = NOW()
+		FROM (
+			SELECT cards.id,
+			       (SELECT COUNT(*) FROM votes WHERE card_id = cards.id AND value = 1 AND status = 'valid') AS likes,
+			       (SELECT COUNT(*) FROM votes WHERE card_id = cards.id AND value = -1 AND status = 'valid') AS dislikes,
+			       (SELECT COUNT(*) FROM comments WHERE card_id = cards.id AND NOT is_internal) AS comments,
+			       (SELECT COUNT(*) FROM comments WHERE card_id = cards.id AND is_internal) AS internal_comments
+			FROM cards
+		) v
+		WHERE v.id = c.id AND (
+			c.likes <> v.likes OR c.dislikes <> v.dislikes OR c.rating IS DISTINCT FROM v.likes - v.dislikes OR
+			c.comment_count <> v.comments OR c.internal_comment_count <> v.internal_comments OR
+			c.hot_score IS DISTINCT FROM card_hot_score(v.likes, v.dislikes, c.created_at) OR
+			c.best_score IS DISTINCT FROM card_best_score(v.likes, v.dislikes) OR
+			c.controversy_score IS DISTINCT FROM card_controversy_score(v.likes, v.dislikes)
+		)
+	`)
+}
+
+// ListCardVotes returns the voters of a card, newest first. Flagged and voided
+// votes are only included when includeUnverified is set.
+func (r *Repository) ListCardVotes(ctx context.Context, cardID int64, includeUnverified bool, limit, offset int) ([]*models.Vote, int, error) {
+	var total int
+	err := r.reader(ctx).QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM votes WHERE card_id = $1 AND (status = 'valid' OR $2)",
+		cardID, includeUnverified,
+	).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.reader(ctx).QueryContext(ctx, `
+		SELECT v.user_id, v.card_id, v.value, v.status, COALESCE(v.flag_reason, ''), v.created_at,
+		       u.id, u.first_name, COALESCE(u.last_name, ''), COALESCE(u.username, ''), COALESCE(u.photo_url, '')
+		FROM votes v
+		JOIN users u ON v.user_id = u.id
+		WHERE v.card_id = $1 AND (v.status = 'valid' OR $2)
+		ORDER BY v.created_at DESC, v.user_id
+		LIMIT $3 OFFSET $4
+	`, cardID, includeUnverified, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var votes []*models.Vote
+	for rows.Next() {
+		v := &models.Vote{User: &models.User{}}
+		err := rows.Scan(
+			&v.UserID, &v.CardID, &v.Value, &v.Status, &v.FlagReason, &v.CreatedAt,
+			&v.User.ID, &v.User.FirstName, &v.User.LastName, &v.User.Username, &v.User.PhotoURL,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !includeUnverified {
+			v.Status, v.FlagReason = "", ""
+		}
+		votes = append(votes, v)
+	}
+	return votes, total, rows.Err()
 }
 
-func (r *Repository) GetUserVote(userID, cardID int64) (int, error) {
+// ListReviewVotes returns votes with the given status (flagged or voided), newest first
+func (r *Repository) ListReviewVotes(ctx context.Context, status string, limit, offset int) ([]*models.Vote, int, error) {
+	var total int
+	if err := r.reader(ctx).QueryRowContext(ctx, "SELECT COUNT(*) FROM votes WHERE status = $1", status).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.reader(ctx).QueryContext(ctx, `
+		SELECT v.user_id, v.card_id, c.title, v.value, v.status, COALESCE(v.flag_reason, ''), v.created_at,
+		       u.id, u.first_name, COALESCE(u.last_name, ''), COALESCE(u.username, ''), COALESCE(u.photo_url, '')
+		FROM votes v
+		JOIN cards c ON v.card_id = c.id
+		JOIN users u ON v.user_id = u.id
+		WHERE v.status = $1
+		ORDER BY v.created_at DESC
+		LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var votes []*models.Vote
+	for rows.Next() {
+		v := &models.Vote{User: &models.User{}}
+		err := rows.Scan(
+			&v.UserID, &v.CardID, &v.CardTitle, &v.Value, &v.Status, &v.FlagReason, &v.CreatedAt,
+			&v.User.ID, &v.User.FirstName, &v.User.LastName, &v.User.Username, &v.User.PhotoURL,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		votes = append(votes, v)
+	}
+	return votes, total, rows.Err()
+}
+
+// SetVoteStatus approves (valid) or voids a vote and recomputes the card's rating.
+// Returns false if the vote does not exist.
+func (r *Repository) SetVoteStatus(ctx context.Context, userID, cardID int64, status string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var lockedID int64
+	err = tx.QueryRowContext(ctx, "SELECT id FROM cards WHERE id = $1 FOR UPDATE", cardID).Scan(&lockedID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	res, err := tx.ExecContext(ctx, "UPDATE votes SET status = $1 WHERE user_id = $2 AND card_id = $3", status, userID, cardID)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	if _, _, _, err := refreshCardVotes(ctx, tx, cardID); err != nil {
+		return false, err
+	}
+	if err := bumpCardsVersion(ctx, tx); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (r *Repository) GetUserVote(ctx context.Context, userID, cardID int64) (int, error) {
 	var value int
-	err := r.db.QueryRow("SELECT value FROM votes WHERE user_id = $1 AND card_id = $2", userID, cardID).Scan(&value)
+	err := r.reader(ctx).QueryRowContext(ctx, "SELECT value FROM votes WHERE user_id = $1 AND card_id = $2", userID, cardID).Scan(&value)
 	if err == sql.ErrNoRows {
 		return 0, nil
 	}
@@ -274,27 +836,178 @@ func (r *Repository) GetUserVote(userID, cardID int64) (int, error) {
 }
 
 // Comment operations
-func (r *Repository) CreateComment(c *models.Comment) error {
+
+// CreateComment creates a comment with the mentions in it and the notifications about it,
+// whose CommentID is set to the new comment's
+func (r *Repository) CreateComment(ctx context.Context, c *models.Comment, mentioned []int64, notify []*models.Notification) error {
 	images := c.Images
 	if images == nil {
 		images = []string{}
 	}
-	err := r.db.QueryRow(`
-		INSERT INTO comments (card_id, user_id, content, images, created_at)
-		VALUES ($1, $2, $3, $4, $5)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO comments (card_id, user_id, content, images, is_internal, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id
-	`, c.CardID, c.UserID, c.Content, pq.Array(images), time.Now()).Scan(&c.ID)
+	`, c.CardID, c.UserID, c.Content, pq.Array(images), c.IsInternal, time.Now()).Scan(&c.ID)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, commentCounterUpdate(c.IsInternal, "+"), c.CardID); err != nil {
+		return err
+	}
+
+	if err := createMentions(ctx, tx, c.CardID, c.ID, c.UserID, mentioned); err != nil {
+		return err
+	}
+	for _, n := range notify {
+		n.CommentID = c.ID
+	}
+	if err := enqueueNotifications(ctx, tx, notify); err != nil {
+		return err
+	}
+	if err := bumpCardsVersion(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// commentCounterUpdate returns the statement that adjusts the card's public or internal comment counter
+func commentCounterUpdate(internal bool, op string) string {
+	column := "comment_count"
+	if internal {
+		column = "internal_comment_count"
+	}
+	return "UPDATE cards SET " + column + " = " + column + " " + op + " 1, updated_at = NOW() WHERE id = $1"
+}
+
+// CommentPage selects a window of a card's comments ordered by ID.
+// After and Before are exclusive comment IDs; without either, Latest selects
+// the newest Limit comments instead of the oldest. Limit 0 means no limit.
+type CommentPage struct {
+	After  int64
+	Before int64
+	Limit  int
+	Latest bool
+}
+
+// ListComments returns a page of comments of a card in ascending order, the total number
+// of comments and whether more exist past the page in its direction.
+// Internal comments are skipped unless includeInternal is set.
+func (r *Repository) ListComments(ctx context.Context, cardID int64, page CommentPage, includeInternal bool) ([]*models.Comment, int, bool, error) {
+	var total int
+	err := r.reader(ctx).QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM comments WHERE card_id = $1 AND (NOT is_internal OR $2)",
+		cardID, includeInternal,
+	).Scan(&total)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	query := `
+		SELECT c.id, c.card_id, c.user_id, c.content, COALESCE(c.images, '{}'), c.is_internal, c.is_official, c.created_at,
+		       u.id, u.first_name, COALESCE(u.last_name, ''), COALESCE(u.username, ''), COALESCE(u.photo_url, '')
+		FROM comments c
+		JOIN users u ON c.user_id = u.id
+		WHERE c.card_id = $1 AND (NOT c.is_internal OR $2)
+	`
+	args := []interface{}{cardID, includeInternal}
+
+	descending := false
+	switch {
+	case page.After > 0:
+		query += " AND c.id > $3 ORDER BY c.id ASC"
+		args = append(args, page.After)
+	case page.Before > 0:
+		query += " AND c.id < $3 ORDER BY c.id DESC"
+		args = append(args, page.Before)
+		descending = true
+	case page.Latest:
+		query += " ORDER BY c.id DESC"
+		descending = true
+	default:
+		query += " ORDER BY c.id ASC"
+	}
+
+	if page.Limit > 0 {
+		// Fetch one extra row to learn whether another page exists
+		query += " LIMIT $" + itoa(len(args)+1)
+		args = append(args, page.Limit+1)
+	}
+
+	rows, err := r.reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer rows.Close()
+
+	var comments []*models.Comment
+	for rows.Next() {
+		c := &models.Comment{Author: &models.User{}}
+		err := rows.Scan(
+			&c.ID, &c.CardID, &c.UserID, &c.Content, pq.Array(&c.Images), &c.IsInternal, &c.IsOfficial, &c.CreatedAt,
+			&c.Author.ID, &c.Author.FirstName, &c.Author.LastName, &c.Author.Username, &c.Author.PhotoURL,
+		)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		comments = append(comments, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, false, err
+	}
+
+	hasMore := page.Limit > 0 && len(comments) > page.Limit
+	if hasMore {
+		comments = comments[:page.Limit]
+	}
+	if descending {
+		for i, j := 0, len(comments)-1; i < j; i, j = i+1, j-1 {
+			comments[i], comments[j] = comments[j], comments[i]
+		}
+	}
+	return comments, total, hasMore, nil
+}
+
+func (r *Repository) GetComment(ctx context.Context, id int64) (*models.Comment, error) {
+	c := &models.Comment{}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, card_id, user_id, content, COALESCE(images, '{}'), is_internal, is_official, created_at
+		FROM comments WHERE id = $1
+	`, id).Scan(&c.ID, &c.CardID, &c.UserID, &c.Content, pq.Array(&c.Images), &c.IsInternal, &c.IsOfficial, &c.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return c, err
+}
+
+func (r *Repository) SetCommentOfficial(ctx context.Context, id int64, official bool) error {
+	_, err := r.execCardChange(ctx, `
+		WITH changed AS (
+			UPDATE comments SET is_official = $1 WHERE id = $2 AND is_official <> $1 RETURNING card_id
+		)
+		UPDATE cards SET updated_at = NOW() WHERE id IN (SELECT card_id FROM changed)
+	`, official, id)
 	return err
 }
 
-func (r *Repository) GetComments(cardID int64) ([]*models.Comment, error) {
-	rows, err := r.db.Query(`
-		SELECT c.id, c.card_id, c.user_id, c.content, COALESCE(c.images, '{}'), c.created_at,
+// GetOfficialResponses returns the comments marked as official responses on a card, newest first
+func (r *Repository) GetOfficialResponses(ctx context.Context, cardID int64) ([]*models.Comment, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `
+		SELECT c.id, c.card_id, c.user_id, c.content, COALESCE(c.images, '{}'), c.is_internal, c.is_official, c.created_at,
 		       u.id, u.first_name, COALESCE(u.last_name, ''), COALESCE(u.username, ''), COALESCE(u.photo_url, '')
 		FROM comments c
 		JOIN users u ON c.user_id = u.id
-		WHERE c.card_id = $1
-		ORDER BY c.created_at ASC
+		WHERE c.card_id = $1 AND c.is_official AND NOT c.is_internal
+		ORDER BY c.created_at DESC
 	`, cardID)
 	if err != nil {
 		return nil, err
@@ -305,7 +1018,7 @@ func (r *Repository) GetComments(cardID int64) ([]*models.Comment, error) {
 	for rows.Next() {
 		c := &models.Comment{Author: &models.User{}}
 		err := rows.Scan(
-			&c.ID, &c.CardID, &c.UserID, &c.Content, pq.Array(&c.Images), &c.CreatedAt,
+			&c.ID, &c.CardID, &c.UserID, &c.Content, pq.Array(&c.Images), &c.IsInternal, &c.IsOfficial, &c.CreatedAt,
 			&c.Author.ID, &c.Author.FirstName, &c.Author.LastName, &c.Author.Username, &c.Author.PhotoURL,
 		)
 		if err != nil {
@@ -313,33 +1026,340 @@ func (r *Repository) GetComments(cardID int64) ([]*models.Comment, error) {
 		}
 		comments = append(comments, c)
 	}
-	return comments, nil
+	return comments, rows.Err()
 }
 
-func (r *Repository) DeleteCard(id int64) error {
-	tx, err := r.db.Begin()
+// GetStaffActivity counts comments, internal ones included, written by the given users since a point in time
+func (r *Repository) GetStaffActivity(ctx context.Context, userIDs []int64, since time.Time) ([]*models.StaffActivity, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `
+		SELECT u.id, u.first_name, COALESCE(u.last_name, ''), COALESCE(u.username, ''), COALESCE(u.photo_url, ''),
+		       COUNT(c.id),
+		       COUNT(c.id) FILTER (WHERE c.is_internal)
+		FROM users u
+		LEFT JOIN comments c ON c.user_id = u.id AND c.created_at >= $2
+		WHERE u.id = ANY($1)
+		GROUP BY u.id
+		ORDER BY COUNT(c.id) DESC, u.id
+	`, pq.Array(userIDs), since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var activity []*models.StaffActivity
+	for rows.Next() {
+		a := &models.StaffActivity{User: &models.User{IsAdmin: true}}
+		err := rows.Scan(
+			&a.User.ID, &a.User.FirstName, &a.User.LastName, &a.User.Username, &a.User.PhotoURL,
+			&a.Comments, &a.InternalComments,
+		)
+		if err != nil {
+			return nil, err
+		}
+		activity = append(activity, a)
+	}
+	return activity, rows.Err()
+}
+
+func (r *Repository) DeleteCard(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	_, err = tx.Exec("DELETE FROM comments WHERE card_id = $1", id)
+	_, err = tx.ExecContext(ctx, "DELETE FROM comments WHERE card_id = $1", id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM votes WHERE card_id = $1", id)
 	if err != nil {
 		return err
 	}
-	_, err = tx.Exec("DELETE FROM votes WHERE card_id = $1", id)
+	res, err := tx.ExecContext(ctx, "DELETE FROM cards WHERE id = $1", id)
 	if err != nil {
 		return err
 	}
-	_, err = tx.Exec("DELETE FROM cards WHERE id = $1", id)
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n > 0 {
+		if err := bumpCardsVersion(ctx, tx); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) DeleteComment(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	var cardID int64
+	var internal bool
+	err = tx.QueryRowContext(ctx, "DELETE FROM comments WHERE id = $1 RETURNING card_id, is_internal", id).Scan(&cardID, &internal)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, commentCounterUpdate(internal, "-"), cardID); err != nil {
+		return err
+	}
+	if err := bumpCardsVersion(ctx, tx); err != nil {
+		return err
+	}
 
 	return tx.Commit()
 }
 
-func (r *Repository) DeleteComment(id int64) error {
-	_, err := r.db.Exec("DELETE FROM comments WHERE id = $1", id)
+// Saved view operations
+
+func (r *Repository) CreateSavedView(ctx context.Context, v *models.SavedView) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO saved_views (user_id, name, sort, type, status, query, tags, share_token, is_public, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id
+	`, v.UserID, v.Name, v.Sort, v.Type, v.Status, v.Query, pq.Array(v.Tags), v.ShareToken, v.IsPublic, v.CreatedAt).Scan(&v.ID)
+}
+
+func (r *Repository) UpdateSavedView(ctx context.Context, v *models.SavedView) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE saved_views SET name = $1, sort = $2, type = $3, status = $4, query = $5, tags = $6, is_public = $7, updated_at = NOW()
+		WHERE id = $8
+	`, v.Name, v.Sort, v.Type, v.Status, v.Query, pq.Array(v.Tags), v.IsPublic, v.ID)
+	return err
+}
+
+func (r *Repository) DeleteSavedView(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM saved_views WHERE id = $1", id)
 	return err
 }
+
+const savedViewColumns = `id, user_id, name, sort, type, status, query, tags, share_token, is_public, created_at`
+
+func scanSavedView(row interface{ Scan(...interface{}) error }) (*models.SavedView, error) {
+	v := &models.SavedView{}
+	err := row.Scan(&v.ID, &v.UserID, &v.Name, &v.Sort, &v.Type, &v.Status, &v.Query, pq.Array(&v.Tags), &v.ShareToken, &v.IsPublic, &v.CreatedAt)
+	return v, err
+}
+
+func (r *Repository) GetSavedView(ctx context.Context, id int64) (*models.SavedView, error) {
+	v, err := scanSavedView(r.db.QueryRowContext(ctx, "SELECT "+savedViewColumns+" FROM saved_views WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return v, err
+}
+
+func (r *Repository) GetSavedViewByToken(ctx context.Context, token string) (*models.SavedView, error) {
+	v, err := scanSavedView(r.reader(ctx).QueryRowContext(ctx, "SELECT "+savedViewColumns+" FROM saved_views WHERE share_token = $1", token))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return v, err
+}
+
+// ListSavedViews returns the published views followed by the user's own views
+func (r *Repository) ListSavedViews(ctx context.Context, userID int64) ([]*models.SavedView, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `
+		SELECT `+savedViewColumns+` FROM saved_views
+		WHERE is_public OR user_id = $1
+		ORDER BY is_public DESC, name, id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var views []*models.SavedView
+	for rows.Next() {
+		v, err := scanSavedView(rows)
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, v)
+	}
+	return views, rows.Err()
+}
+
+// Reaction operations. Reactions are shown with their card, so an actual change
+// also touches the card's updated_at and advances the version of the card lists.
+func (r *Repository) AddCardReaction(ctx context.Context, cardID, userID int64, emoji string) error {
+	_, err := r.execCardChange(ctx, `
+		WITH added AS (
+			INSERT INTO card_reactions (card_id, user_id, emoji) VALUES ($1, $2, $3)
+			ON CONFLICT DO NOTHING
+			RETURNING card_id
+		)
+		UPDATE cards SET updated_at = NOW() WHERE id IN (SELECT card_id FROM added)
+	`, cardID, userID, emoji)
+	return err
+}
+
+func (r *Repository) RemoveCardReaction(ctx context.Context, cardID, userID int64, emoji string) error {
+	_, err := r.execCardChange(ctx, `
+		WITH removed AS (
+			DELETE FROM card_reactions WHERE card_id = $1 AND user_id = $2 AND emoji = $3
+			RETURNING card_id
+		)
+		UPDATE cards SET updated_at = NOW() WHERE id IN (SELECT card_id FROM removed)
+	`, cardID, userID, emoji)
+	return err
+}
+
+func (r *Repository) AddCommentReaction(ctx context.Context, commentID, userID int64, emoji string) error {
+	_, err := r.execCardChange(ctx, `
+		WITH added AS (
+			INSERT INTO comment_reactions (comment_id, user_id, emoji) VALUES ($1, $2, $3)
+			ON CONFLICT DO NOTHING
+			RETURNING comment_id
+		)
+		UPDATE cards SET updated_at = NOW() WHERE id IN (SELECT card_id FROM comments WHERE id IN (SELECT comment_id FROM added))
+	`, commentID, userID, emoji)
+	return err
+}
+
+func (r *Repository) RemoveCommentReaction(ctx context.Context, commentID, userID int64, emoji string) error {
+	_, err := r.execCardChange(ctx, `
+		WITH removed AS (
+			DELETE FROM comment_reactions WHERE comment_id = $1 AND user_id = $2 AND emoji = $3
+			RETURNING comment_id
+		)
+		UPDATE cards SET updated_at = NOW() WHERE id IN (SELECT card_id FROM comments WHERE id IN (SELECT comment_id FROM removed))
+	`, commentID, userID, emoji)
+	return err
+}
+
+// GetCardReactions returns aggregated reactions keyed by card ID
+func (r *Repository) GetCardReactions(ctx context.Context, cardIDs []int64, userID int64) (map[int64][]models.Reaction, error) {
+	return r.getReactions(ctx, "card_reactions", "card_id", cardIDs, userID)
+}
+
+// GetCommentReactions returns aggregated reactions keyed by comment ID
+func (r *Repository) GetCommentReactions(ctx context.Context, commentIDs []int64, userID int64) (map[int64][]models.Reaction, error) {
+	return r.getReactions(ctx, "comment_reactions", "comment_id", commentIDs, userID)
+}
+
+func (r *Repository) getReactions(ctx context.Context, table, column string, ids []int64, userID int64) (map[int64][]models.Reaction, error) {
+	reactions := make(map[int64][]models.Reaction)
+	if len(ids) == 0 {
+		return reactions, nil
+	}
+
+	rows, err := r.reader(ctx).QueryContext(ctx, `
+		SELECT `+column+`, emoji, COUNT(*), BOOL_OR(user_id = $2)
+		FROM `+table+`
+		WHERE `+column+` = ANY($1)
+		GROUP BY `+column+`, emoji
+		ORDER BY MIN(created_at)
+	`, pq.Array(ids), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var re models.Reaction
+		if err := rows.Scan(&id, &re.Emoji, &re.Count, &re.Reacted); err != nil {
+			return nil, err
+		}
+		reactions[id] = append(reactions[id], re)
+	}
+	return reactions, rows.Err()
+}
+
+// Mention operations
+func (r *Repository) GetUsersByUsernames(ctx context.Context, usernames []string) ([]*models.User, error) {
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+	lowered := make([]string, len(usernames))
+	for i, name := range usernames {
+		lowered[i] = strings.ToLower(name)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, first_name, COALESCE(last_name, ''), COALESCE(username, ''), COALESCE(photo_url, ''), auth_date, is_admin
+		FROM users WHERE LOWER(username) = ANY($1)
+	`, pq.Array(lowered))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		u := &models.User{}
+		if err := rows.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Username, &u.PhotoURL, &u.AuthDate, &u.IsAdmin); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// createMentions records that userIDs were mentioned by authorID on a card,
+// or in one of its comments when commentID is non-zero. Existing mentions are kept.
+func createMentions(ctx context.Context, tx *sql.Tx, cardID, commentID, authorID int64, userIDs []int64) error {
+	var commentRef interface{}
+	if commentID != 0 {
+		commentRef = commentID
+	}
+
+	for _, userID := range userIDs {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO mentions (user_id, card_id, comment_id, author_id, created_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT DO NOTHING
+		`, userID, cardID, commentRef, authorID, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Repository) ListMentions(ctx context.Context, userID int64, limit, offset int) ([]*models.Mention, int, error) {
+	var total int
+	if err := r.reader(ctx).QueryRowContext(ctx, "SELECT COUNT(*) FROM mentions WHERE user_id = $1", userID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.reader(ctx).QueryContext(ctx, `
+		SELECT m.id, m.user_id, m.card_id, c.title, COALESCE(m.comment_id, 0),
+		       COALESCE(cm.content, c.description, ''), m.created_at,
+		       u.id, u.first_name, COALESCE(u.last_name, ''), COALESCE(u.username, ''), COALESCE(u.photo_url, '')
+		FROM mentions m
+		JOIN cards c ON m.card_id = c.id
+		JOIN users u ON m.author_id = u.id
+		LEFT JOIN comments cm ON m.comment_id = cm.id
+		WHERE m.user_id = $1
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT $2 OFFSET $3
+	`, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var mentions []*models.Mention
+	for rows.Next() {
+		m := &models.Mention{Author: &models.User{}}
+		err := rows.Scan(
+			&m.ID, &m.UserID, &m.CardID, &m.CardTitle, &m.CommentID,
+			&m.Content, &m.CreatedAt,
+			&m.Author.ID, &m.Author.FirstName, &m.Author.LastName, &m.Author.Username, &m.Author.PhotoURL,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		mentions = append(mentions, m)
+	}
+	return mentions, total, rows.Err()
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"bugtracker/internal/models"
+)
+
+// Store is the storage used by the handlers. Repository implements it on PostgreSQL;
+// package memory implements it in memory for tests. Lookups of a missing row return
+// nil and no error.
+type Store interface {
+	// Users
+	UpsertUser(ctx context.Context, u *models.User) error
+	GetUser(ctx context.Context, id int64) (*models.User, error)
+	GetUsersByUsernames(ctx context.Context, usernames []string) ([]*models.User, error)
+
+	// Cards
+	CreateCard(ctx context.Context, c *models.Card, mentioned []int64, notify []*models.Notification) error
+	GetCard(ctx context.Context, id int64, includeInternal bool) (*models.Card, error)
+	ListCards(ctx context.Context, filter CardFilter, sort string, page CardPage, userID int64, includeInternal bool) ([]*models.Card, int, *CardCursor, error)
+	CountCards(ctx context.Context, filter CardFilter) (int, error)
+	CountCardsBatch(ctx context.Context, filters []CardFilter) ([]int, error)
+	CardFacets(ctx context.Context, filter CardFilter) (*models.CardFacets, error)
+	CardsVersion(ctx context.Context) (string, error)
+	CardVersion(ctx context.Context, id int64) (string, error)
+	Search(ctx context.Context, query string, limit, offset, commentsPerCard int, includeInternal bool) ([]*models.SearchResult, int, error)
+	FindSimilarCards(ctx context.Context, title string, opts SimilarCardsOptions) ([]*models.SimilarCard, error)
+	UpdateCardStatus(ctx context.Context, id int64, status string, notify []*models.Notification) error
+	DeleteCard(ctx context.Context, id int64) error
+
+	// Votes
+	GetVoteBudgetUsed(ctx context.Context, userID int64, since time.Time) (int, error)
+	ToggleVote(ctx context.Context, userID, cardID int64, value int, rules VoteRules, budget VoteBudget) (*models.VoteResult, error)
+	ReconcileCards(ctx context.Context) (int64, error)
+	ListCardVotes(ctx context.Context, cardID int64, includeUnverified bool, limit, offset int) ([]*models.Vote, int, error)
+	ListReviewVotes(ctx context.Context, status string, limit, offset int) ([]*models.Vote, int, error)
+	SetVoteStatus(ctx context.Context, userID, cardID int64, status string) (bool, error)
+	GetUserVote(ctx context.Context, userID, cardID int64) (int, error)
+
+	// Comments
+	CreateComment(ctx context.Context, c *models.Comment, mentioned []int64, notify []*models.Notification) error
+	ListComments(ctx context.Context, cardID int64, page CommentPage, includeInternal bool) ([]*models.Comment, int, bool, error)
+	GetComment(ctx context.Context, id int64) (*models.Comment, error)
+	SetCommentOfficial(ctx context.Context, id int64, official bool) error
+	GetOfficialResponses(ctx context.Context, cardID int64) ([]*models.Comment, error)
+	GetStaffActivity(ctx context.Context, userIDs []int64, since time.Time) ([]*models.StaffActivity, error)
+	DeleteComment(ctx context.Context, id int64) error
+
+	// Saved views
+	CreateSavedView(ctx context.Context, v *models.SavedView) error
+	UpdateSavedView(ctx context.Context, v *models.SavedView) error
+	DeleteSavedView(ctx context.Context, id int64) error
+	GetSavedView(ctx context.Context, id int64) (*models.SavedView, error)
+	GetSavedViewByToken(ctx context.Context, token string) (*models.SavedView, error)
+	ListSavedViews(ctx context.Context, userID int64) ([]*models.SavedView, error)
+
+	// Reactions
+	AddCardReaction(ctx context.Context, cardID, userID int64, emoji string) error
+	RemoveCardReaction(ctx context.Context, cardID, userID int64, emoji string) error
+	AddCommentReaction(ctx context.Context, commentID, userID int64, emoji string) error
+	RemoveCommentReaction(ctx context.Context, commentID, userID int64, emoji string) error
+	GetCardReactions(ctx context.Context, cardIDs []int64, userID int64) (map[int64][]models.Reaction, error)
+	GetCommentReactions(ctx context.Context, commentIDs []int64, userID int64) (map[int64][]models.Reaction, error)
+
+	// Mentions
+	ListMentions(ctx context.Context, userID int64, limit, offset int) ([]*models.Mention, int, error)
+
+	// Notification outbox
+	ClaimNotifications(ctx context.Context, limit int, lease time.Duration) ([]*models.Notification, error)
+	DeleteNotification(ctx context.Context, id int64) error
+	FailNotification(ctx context.Context, id int64, lastError string, retryAt time.Time) error
+	ListNotifications(ctx context.Context, state string, limit, offset int) ([]*models.Notification, int, error)
+	RetryNotification(ctx context.Context, id int64) (bool, error)
+	RetryDeadNotifications(ctx context.Context) (int64, error)
+	DeferNotification(ctx context.Context, id int64, until time.Time) error
+
+	// Notification preferences
+	GetNotificationPreferences(ctx context.Context, userID int64) (*models.NotificationPreferences, error)
+	SaveNotificationPreferences(ctx context.Context, p *models.NotificationPreferences) error
+}
+
+var _ Store = (*Repository)(nil)
@@ -0,0 +1,428 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"bugtracker/internal/migrate"
+	"bugtracker/internal/models"
+	"bugtracker/internal/repository"
+	"bugtracker/internal/repository/memory"
+)
+
+// The store contract is the behaviour of repository.Store that the handlers rely on. It
+// runs against the in-memory store the handler tests use and, with DATABASE_URL set,
+// against PostgreSQL in a temporary schema, so the two implementations can't drift apart.
+
+func TestMemoryStoreContract(t *testing.T) {
+	testStoreContract(t, func(t *testing.T) repository.Store { return memory.New() })
+}
+
+func TestRepositoryContract(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+	testStoreContract(t, func(t *testing.T) repository.Store { return newTestRepository(t, dsn) })
+}
+
+// newTestRepository migrates a new schema, dropped after the test, and returns a
+// Repository using it. Extensions and functions in public stay visible.
+func newTestRepository(t *testing.T, dsn string) *repository.Repository {
+	t.Helper()
+	return repository.New(newTestDB(t, dsn))
+}
+
+// newTestDB migrates a new schema, dropped after the test, and returns a connection
+// pool using it
+func newTestDB(t *testing.T, dsn string) *sql.DB {
+	t.Helper()
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("store_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("dropping %s: %v", schema, err)
+		}
+	})
+
+	searchPath := schema + ",public"
+	if u, err := url.Parse(dsn); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		q := u.Query()
+		q.Set("search_path", searchPath)
+		u.RawQuery = q.Encode()
+		dsn = u.String()
+	} else {
+		dsn += " search_path=" + searchPath
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := migrate.Run(db, "../../migrations"); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// Reconciliation also repairs ranking scores that drifted while the counters are right
+func TestReconcileRepairsScores(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+	db := newTestDB(t, dsn)
+	repo := repository.New(db)
+	if err := repo.UpsertUser(ctx, &models.User{ID: 1001, FirstName: "Alice", AuthDate: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	card := &models.Card{UserID: 1001, Title: "Crash on start", Type: "issue", Status: "open"}
+	if err := repo.CreateCard(ctx, card, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.ToggleVote(ctx, 1001, card.ID, 1, repository.VoteRules{}, repository.VoteBudget{}); err != nil {
+		t.Fatal(err)
+	}
+	if fixed, err := repo.ReconcileCards(ctx); fixed != 0 || err != nil {
+		t.Fatalf("ReconcileCards on a consistent card = %d, %v", fixed, err)
+	}
+
+	for _, column := range []string{"hot_score", "best_score", "controversy_score"} {
+		if _, err := db.ExecContext(ctx, "UPDATE cards SET "+column+" = 42 WHERE id = $1", card.ID); err != nil {
+			t.Fatal(err)
+		}
+		if fixed, err := repo.ReconcileCards(ctx); fixed != 1 || err != nil {
+			t.Errorf("ReconcileCards with a drifted %s = %d, %v", column, fixed, err)
+		}
+		var drifted bool
+		err := db.QueryRowContext(ctx, `
+			SELECT hot_score IS DISTINCT FROM card_hot_score(likes, dislikes, created_at) OR
+			       best_score IS DISTINCT FROM card_best_score(likes, dislikes) OR
+			       controversy_score IS DISTINCT FROM card_controversy_score(likes, dislikes)
+			FROM cards WHERE id = $1
+		`, card.ID).Scan(&drifted)
+		if err != nil || drifted {
+			t.Errorf("scores after reconciling %s: drifted = %v, %v", column, drifted, err)
+		}
+	}
+}
+
+func testStoreContract(t *testing.T, newStore func(t *testing.T) repository.Store) {
+	ctx := context.Background()
+	const alice, bob = 1001, 1002
+
+	// setup returns a store with two users and a card by alice
+	setup := func(t *testing.T) (repository.Store, *models.Card) {
+		t.Helper()
+		s := newStore(t)
+		for _, u := range []*models.User{
+			{ID: alice, FirstName: "Alice", Username: "alice", AuthDate: time.Now()},
+			{ID: bob, FirstName: "Bob", Username: "Bob", AuthDate: time.Now()},
+		} {
+			if err := s.UpsertUser(ctx, u); err != nil {
+				t.Fatal(err)
+			}
+		}
+		card := &models.Card{UserID: alice, Title: "Crash on start", Description: "The app crashes", Type: "issue", Status: "open"}
+		if err := s.CreateCard(ctx, card, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		return s, card
+	}
+
+	t.Run("users", func(t *testing.T) {
+		s, _ := setup(t)
+		if err := s.UpsertUser(ctx, &models.User{ID: alice, FirstName: "Alicia", Username: "alice", AuthDate: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+		u, err := s.GetUser(ctx, alice)
+		if err != nil || u == nil || u.FirstName != "Alicia" {
+			t.Errorf("GetUser = %+v, %v", u, err)
+		}
+		if u, err := s.GetUser(ctx, 9999); u != nil || err != nil {
+			t.Errorf("GetUser(missing) = %+v, %v", u, err)
+		}
+		users, err := s.GetUsersByUsernames(ctx, []string{"ALICE", "bob", "nobody"})
+		if err != nil || len(users) != 2 {
+			t.Errorf("GetUsersByUsernames = %d users, %v", len(users), err)
+		}
+	})
+
+	t.Run("cards", func(t *testing.T) {
+		s, first := setup(t)
+		second := &models.Card{UserID: bob, Title: "Dark theme", Type: "suggestion", Status: "open"}
+		if err := s.CreateCard(ctx, second, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if first.ID == 0 || second.ID == 0 || first.ID == second.ID {
+			t.Fatalf("card ids %d, %d", first.ID, second.ID)
+		}
+
+		c, err := s.GetCard(ctx, first.ID, false)
+		if err != nil || c == nil || c.Title != first.Title || c.Author == nil || c.Author.Username != "alice" {
+			t.Fatalf("GetCard = %+v, %v", c, err)
+		}
+		if c, err := s.GetCard(ctx, 9999, false); c != nil || err != nil {
+			t.Errorf("GetCard(missing) = %+v, %v", c, err)
+		}
+
+		cards, total, next, err := s.ListCards(ctx, repository.CardFilter{}, "time", repository.CardPage{Limit: 1}, 0, false)
+		if err != nil || total != 2 || len(cards) != 1 || cards[0].ID != second.ID || next == nil {
+			t.Fatalf("first page = %d cards, total %d, next %v, %v", len(cards), total, next, err)
+		}
+		cards, _, next, err = s.ListCards(ctx, repository.CardFilter{}, "time", repository.CardPage{Limit: 1, After: next, Total: repository.TotalNone}, 0, false)
+		if err != nil || len(cards) != 1 || cards[0].ID != first.ID || next != nil {
+			t.Errorf("second page = %d cards, next %v, %v", len(cards), next, err)
+		}
+
+		issues := repository.CardFilter{Types: []string{"issue"}}
+		byBob := repository.CardFilter{Authors: []string{"BOB"}}
+		if n, err := s.CountCards(ctx, issues); n != 1 || err != nil {
+			t.Errorf("CountCards(issues) = %d, %v", n, err)
+		}
+		counts, err := s.CountCardsBatch(ctx, []repository.CardFilter{{}, issues, byBob, {Statuses: []string{"fixed"}}})
+		if err != nil || fmt.Sprint(counts) != "[2 1 1 0]" {
+			t.Errorf("CountCardsBatch = %v, %v", counts, err)
+		}
+
+		version, err := s.CardsVersion(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.UpdateCardStatus(ctx, first.ID, "fixed", nil); err != nil {
+			t.Fatal(err)
+		}
+		if c, _ := s.GetCard(ctx, first.ID, false); c == nil || c.Status != "fixed" {
+			t.Errorf("status after update = %+v", c)
+		}
+		if v, err := s.CardsVersion(ctx); v == version || err != nil {
+			t.Errorf("CardsVersion after update = %q, %v", v, err)
+		}
+		version, _ = s.CardsVersion(ctx)
+		if err := s.DeleteCard(ctx, second.ID); err != nil {
+			t.Fatal(err)
+		}
+		if c, err := s.GetCard(ctx, second.ID, false); c != nil || err != nil {
+			t.Errorf("GetCard(deleted) = %+v, %v", c, err)
+		}
+		if v, err := s.CardsVersion(ctx); v == version || err != nil {
+			t.Errorf("CardsVersion after delete = %q, %v", v, err)
+		}
+	})
+
+	t.Run("votes", func(t *testing.T) {
+		s, card := setup(t)
+		res, err := s.ToggleVote(ctx, bob, card.ID, 1, repository.VoteRules{}, repository.VoteBudget{})
+		if err != nil || res.Value != 1 || res.Rating != 1 || res.Likes != 1 || res.Dislikes != 0 {
+			t.Fatalf("upvote = %+v, %v", res, err)
+		}
+		if v, err := s.GetUserVote(ctx, bob, card.ID); v != 1 || err != nil {
+			t.Errorf("GetUserVote = %d, %v", v, err)
+		}
+		res, err = s.ToggleVote(ctx, bob, card.ID, -1, repository.VoteRules{}, repository.VoteBudget{})
+		if err != nil || res.Value != -1 || res.Rating != -1 || res.Likes != 0 || res.Dislikes != 1 {
+			t.Errorf("switch to downvote = %+v, %v", res, err)
+		}
+		res, err = s.ToggleVote(ctx, bob, card.ID, -1, repository.VoteRules{}, repository.VoteBudget{})
+		if err != nil || res.Value != 0 || res.Rating != 0 || res.Dislikes != 0 {
+			t.Errorf("withdraw = %+v, %v", res, err)
+		}
+		if c, _ := s.GetCard(ctx, card.ID, false); c == nil || c.Rating != 0 || c.Likes != 0 || c.Dislikes != 0 {
+			t.Errorf("card after votes = %+v", c)
+		}
+	})
+
+	t.Run("comments", func(t *testing.T) {
+		s, card := setup(t)
+		public := &models.Comment{CardID: card.ID, UserID: bob, Content: "Me too"}
+		internal := &models.Comment{CardID: card.ID, UserID: alice, Content: "Known issue", IsInternal: true}
+		for _, c := range []*models.Comment{public, internal} {
+			if err := s.CreateComment(ctx, c, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if c, _ := s.GetCard(ctx, card.ID, false); c == nil || c.CommentCount != 1 {
+			t.Errorf("public comment count = %+v", c)
+		}
+		if c, _ := s.GetCard(ctx, card.ID, true); c == nil || c.CommentCount != 2 {
+			t.Errorf("comment count with internal = %+v", c)
+		}
+
+		comments, total, more, err := s.ListComments(ctx, card.ID, repository.CommentPage{Limit: 10}, false)
+		if err != nil || total != 1 || more || len(comments) != 1 || comments[0].ID != public.ID {
+			t.Errorf("ListComments = %d comments, total %d, more %v, %v", len(comments), total, more, err)
+		}
+		if c, err := s.GetComment(ctx, 9999); c != nil || err != nil {
+			t.Errorf("GetComment(missing) = %+v, %v", c, err)
+		}
+
+		if err := s.DeleteComment(ctx, public.ID); err != nil {
+			t.Fatal(err)
+		}
+		if c, _ := s.GetCard(ctx, card.ID, true); c == nil || c.CommentCount != 1 {
+			t.Errorf("comment count after delete = %+v", c)
+		}
+	})
+
+	t.Run("saved views", func(t *testing.T) {
+		s, _ := setup(t)
+		v := &models.SavedView{UserID: alice, Name: "Mine", Sort: "rate", Tags: []string{}, ShareToken: "token-1", CreatedAt: time.Now()}
+		if err := s.CreateSavedView(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.CreateSavedView(ctx, &models.SavedView{UserID: bob, Name: "Copy", Tags: []string{}, ShareToken: "token-1"}); err == nil {
+			t.Error("duplicate share token accepted")
+		}
+
+		v.Name, v.Tags, v.IsPublic = "Android", []string{"android"}, true
+		if err := s.UpdateSavedView(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.GetSavedViewByToken(ctx, "token-1")
+		if err != nil || got == nil || got.ID != v.ID || got.Name != "Android" || fmt.Sprint(got.Tags) != "[android]" || !got.IsPublic {
+			t.Errorf("GetSavedViewByToken = %+v, %v", got, err)
+		}
+		if views, err := s.ListSavedViews(ctx, bob); err != nil || len(views) != 1 {
+			t.Errorf("bob's list = %d views, %v", len(views), err)
+		}
+
+		if err := s.DeleteSavedView(ctx, v.ID); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.GetSavedView(ctx, v.ID); got != nil || err != nil {
+			t.Errorf("GetSavedView(deleted) = %+v, %v", got, err)
+		}
+	})
+
+	t.Run("notification outbox", func(t *testing.T) {
+		s, card := setup(t)
+		comment := &models.Comment{CardID: card.ID, UserID: bob, Content: "Me too"}
+		notify := []*models.Notification{{UserID: alice, Event: "comment", CardID: card.ID, ActorID: bob}}
+		if err := s.CreateComment(ctx, comment, nil, notify); err != nil {
+			t.Fatal(err)
+		}
+
+		claimed, err := s.ClaimNotifications(ctx, 10, time.Minute)
+		if err != nil || len(claimed) != 1 || claimed[0].CommentID != comment.ID || claimed[0].Attempts != 1 {
+			t.Fatalf("ClaimNotifications = %+v, %v", claimed, err)
+		}
+		// Leased notifications are not handed out twice
+		if again, err := s.ClaimNotifications(ctx, 10, time.Minute); len(again) != 0 || err != nil {
+			t.Errorf("claimed again = %+v, %v", again, err)
+		}
+
+		if err := s.FailNotification(ctx, claimed[0].ID, "telegram is down", time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+		dead, total, err := s.ListNotifications(ctx, repository.NotificationDead, 10, 0)
+		if err != nil || total != 1 || len(dead) != 1 || dead[0].LastError != "telegram is down" {
+			t.Fatalf("dead letters = %+v, total %d, %v", dead, total, err)
+		}
+		if ok, err := s.RetryNotification(ctx, dead[0].ID); !ok || err != nil {
+			t.Fatalf("RetryNotification = %v, %v", ok, err)
+		}
+		claimed, err = s.ClaimNotifications(ctx, 10, time.Minute)
+		if err != nil || len(claimed) != 1 {
+			t.Fatalf("claim after retry = %+v, %v", claimed, err)
+		}
+		if err := s.DeleteNotification(ctx, claimed[0].ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, total, err := s.ListNotifications(ctx, repository.NotificationPending, 10, 0); total != 0 || err != nil {
+			t.Errorf("pending after delivery = %d, %v", total, err)
+		}
+	})
+
+	t.Run("notification preferences", func(t *testing.T) {
+		s, _ := setup(t)
+		if p, err := s.GetNotificationPreferences(ctx, alice); p != nil || err != nil {
+			t.Errorf("preferences before saving = %+v, %v", p, err)
+		}
+		prefs := &models.NotificationPreferences{UserID: alice, Timezone: "Europe/Moscow", QuietStart: "22:00", QuietEnd: "08:00", Muted: []string{"comment:telegram"}}
+		if err := s.SaveNotificationPreferences(ctx, prefs); err != nil {
+			t.Fatal(err)
+		}
+		p, err := s.GetNotificationPreferences(ctx, alice)
+		if err != nil || p == nil || p.Timezone != "Europe/Moscow" || p.QuietEnd != "08:00" || fmt.Sprint(p.Muted) != "[comment:telegram]" {
+			t.Errorf("GetNotificationPreferences = %+v, %v", p, err)
+		}
+	})
+}
+
+// A write that started before another but commits after it still changes the version
+// of the card lists, though its updated_at is older than the other's
+func TestCardsVersionFollowsCommitOrder(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+	db := newTestDB(t, dsn)
+	repo := repository.New(db)
+	if err := repo.UpsertUser(ctx, &models.User{ID: 1001, FirstName: "Alice", AuthDate: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	older := &models.Card{UserID: 1001, Title: "Crash on start", Type: "issue", Status: "open"}
+	newer := &models.Card{UserID: 1001, Title: "Dark mode", Type: "suggestion", Status: "open"}
+	for _, c := range []*models.Card{older, newer} {
+		if err := repo.CreateCard(ctx, c, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Hold the older card's row so that a vote on it starts and then waits
+	lock, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Rollback()
+	if _, err := lock.ExecContext(ctx, "SELECT id FROM cards WHERE id = $1 FOR UPDATE", older.ID); err != nil {
+		t.Fatal(err)
+	}
+	voted := make(chan error, 1)
+	go func() {
+		_, err := repo.ToggleVote(ctx, 1001, older.ID, 1, repository.VoteRules{}, repository.VoteBudget{})
+		voted <- err
+	}()
+	for waiting := 0; waiting == 0; {
+		err := db.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM pg_stat_activity WHERE datname = current_database() AND wait_event_type = 'Lock'
+		`).Scan(&waiting)
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := repo.ToggleVote(ctx, 1001, newer.ID, 1, repository.VoteRules{}, repository.VoteBudget{}); err != nil {
+		t.Fatal(err)
+	}
+	version, err := repo.CardsVersion(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lock.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-voted; err != nil {
+		t.Fatal(err)
+	}
+	if v, err := repo.CardsVersion(ctx); v == version || err != nil {
+		t.Errorf("CardsVersion after the older vote committed = %q, %v; was %q", v, err, version)
+	}
+}
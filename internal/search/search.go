@@ -0,0 +1,270 @@
+// Package search parses the card search box syntax, e.g.
+//
+//	status:open type:issue tag:android author:@ivan votes:>10 created:>2026-01-01 "crash on start"
+//
+// into a typed Query. Terms are separated by spaces; a term is either a field
+// filter (field:value, field:>value, field:"quoted value") or free text, where
+// "quoted text" is matched as a phrase. Only the fields below make a filter: any
+// other word with a colon, such as a URL or "note:", is free text.
+package search
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"bugtracker/internal/models"
+)
+
+type Field string
+
+const (
+	FieldStatus  Field = "status"
+	FieldType    Field = "type"
+	FieldTag     Field = "tag"
+	FieldAuthor  Field = "author"
+	FieldVotes   Field = "votes"
+	FieldCreated Field = "created"
+)
+
+var fields = map[Field]bool{
+	FieldStatus: true, FieldType: true, FieldTag: true, FieldAuthor: true, FieldVotes: true, FieldCreated: true,
+}
+
+type Op string
+
+const (
+	OpEq  Op = "="
+	OpGt  Op = ">"
+	OpGte Op = ">="
+	OpLt  Op = "<"
+	OpLte Op = "<="
+)
+
+// Filter is a field:value term. Int is set for votes, Date for created
+// (midnight UTC of the given day); other fields use Value.
+type Filter struct {
+	Field Field     `json:"field"`
+	Op    Op        `json:"op"`
+	Value string    `json:"value"`
+	Int   int       `json:"-"`
+	Date  time.Time `json:"-"`
+	Pos   int       `json:"pos"`
+	End   int       `json:"end"`
+}
+
+// Text is a free-text word or a quoted phrase
+type Text struct {
+	Value  string `json:"value"`
+	Phrase bool   `json:"phrase"`
+	Pos    int    `json:"pos"`
+	End    int    `json:"end"`
+}
+
+type Query struct {
+	Filters []Filter `json:"filters"`
+	Text    []Text   `json:"text"`
+}
+
+// SyntaxError describes an invalid term. Pos and End are character (rune)
+// offsets into the input delimiting the part to underline.
+type SyntaxError struct {
+	Message string `json:"message"`
+	Pos     int    `json:"pos"`
+	End     int    `json:"end"`
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Message, e.Pos)
+}
+
+// FullText returns the free-text part of the query in web search syntax,
+// with phrases quoted, or an empty string if there is none.
+func (q *Query) FullText() string {
+	parts := make([]string, 0, len(q.Text))
+	for _, t := range q.Text {
+		if t.Phrase {
+			parts = append(parts, `"`+t.Value+`"`)
+		} else {
+			parts = append(parts, t.Value)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Parse parses a search box input. It returns a *SyntaxError for invalid input.
+func Parse(input string) (*Query, error) {
+	p := &parser{input: []rune(input)}
+	q := &Query{}
+
+	for {
+		p.skipSpaces()
+		if p.eof() {
+			return q, nil
+		}
+
+		start := p.pos
+		if p.peek() == '"' {
+			value, err := p.quoted()
+			if err != nil {
+				return nil, err
+			}
+			if value != "" {
+				q.Text = append(q.Text, Text{Value: value, Phrase: true, Pos: start, End: p.pos})
+			}
+			continue
+		}
+
+		word := p.word()
+		if name, ok := fieldName(word); ok {
+			f, err := p.filter(name, start)
+			if err != nil {
+				return nil, err
+			}
+			q.Filters = append(q.Filters, *f)
+			continue
+		}
+		q.Text = append(q.Text, Text{Value: word, Pos: start, End: p.pos})
+	}
+}
+
+type parser struct {
+	input []rune
+	pos   int
+}
+
+func (p *parser) eof() bool  { return p.pos >= len(p.input) }
+func (p *parser) peek() rune { return p.input[p.pos] }
+
+func (p *parser) skipSpaces() {
+	for !p.eof() && unicode.IsSpace(p.peek()) {
+		p.pos++
+	}
+}
+
+// word reads up to the next space or, for a field name, up to and including the colon
+func (p *parser) word() string {
+	start := p.pos
+	for !p.eof() && !unicode.IsSpace(p.peek()) {
+		r := p.peek()
+		p.pos++
+		if r == ':' && !p.hasPrefix("//") { // keep URLs as text
+			if _, ok := fieldName(string(p.input[start:p.pos])); ok {
+				break
+			}
+		}
+	}
+	return string(p.input[start:p.pos])
+}
+
+// quoted reads a "quoted string" starting at the current position
+func (p *parser) quoted() (string, error) {
+	start := p.pos
+	p.pos++ // opening quote
+	for !p.eof() && p.peek() != '"' {
+		p.pos++
+	}
+	if p.eof() {
+		return "", &SyntaxError{Message: "unterminated quote", Pos: start, End: p.pos}
+	}
+	p.pos++ // closing quote
+	return strings.TrimSpace(string(p.input[start+1 : p.pos-1])), nil
+}
+
+// fieldName reports whether word is "name:" with the name of a known field
+func fieldName(word string) (Field, bool) {
+	name, ok := strings.CutSuffix(word, ":")
+	if !ok || !fields[Field(name)] {
+		return "", false
+	}
+	return Field(name), true
+}
+
+// filter parses the value of a field term whose "name:" starts at start
+func (p *parser) filter(field Field, start int) (*Filter, error) {
+	f := &Filter{Field: field, Op: OpEq, Pos: start}
+	valueStart := p.pos
+
+	for _, op := range []Op{OpGte, OpLte, OpGt, OpLt, OpEq} {
+		if p.hasPrefix(string(op)) {
+			f.Op = op
+			p.pos += len(op)
+			break
+		}
+	}
+
+	var value string
+	if !p.eof() && p.peek() == '"' {
+		var err error
+		if value, err = p.quoted(); err != nil {
+			return nil, err
+		}
+	} else {
+		value = p.skipValue()
+	}
+	f.Value = value
+	f.End = p.pos
+
+	fail := func(format string, args ...interface{}) (*Filter, error) {
+		return nil, &SyntaxError{Message: fmt.Sprintf(format, args...), Pos: valueStart, End: p.pos}
+	}
+
+	if value == "" {
+		return nil, &SyntaxError{Message: fmt.Sprintf("missing value for %s", field), Pos: start, End: p.pos}
+	}
+
+	switch field {
+	case FieldVotes:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fail("votes must be a number")
+		}
+		f.Int = n
+		return f, nil
+	case FieldCreated:
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return fail("created must be a date like 2026-01-31")
+		}
+		f.Date = date
+		return f, nil
+	}
+
+	if f.Op != OpEq {
+		return fail("%s does not support %s", field, f.Op)
+	}
+
+	switch field {
+	case FieldStatus:
+		f.Value = strings.ToLower(value)
+		if !models.CardStatuses[f.Value] {
+			return fail("unknown status %q", value)
+		}
+	case FieldType:
+		f.Value = strings.ToLower(value)
+		if !models.CardTypes[f.Value] {
+			return fail("unknown type %q", value)
+		}
+	case FieldAuthor:
+		f.Value = strings.TrimPrefix(value, "@")
+		if f.Value == "" {
+			return fail("missing username")
+		}
+	}
+	return f, nil
+}
+
+func (p *parser) hasPrefix(s string) bool {
+	return strings.HasPrefix(string(p.input[p.pos:]), s)
+}
+
+// skipValue reads an unquoted value up to the next space
+func (p *parser) skipValue() string {
+	start := p.pos
+	for !p.eof() && !unicode.IsSpace(p.peek()) {
+		p.pos++
+	}
+	return string(p.input[start:p.pos])
+}
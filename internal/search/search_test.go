@@ -0,0 +1,134 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseFilters(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	for _, tc := range []struct {
+		input string
+		want  Filter
+	}{
+		{"status:open", Filter{Field: FieldStatus, Op: OpEq, Value: "open", End: 11}},
+		{"status:Fix_Coming", Filter{Field: FieldStatus, Op: OpEq, Value: "fix_coming", End: 17}},
+		{"type:suggestion", Filter{Field: FieldType, Op: OpEq, Value: "suggestion", End: 15}},
+		{"tag:android", Filter{Field: FieldTag, Op: OpEq, Value: "android", End: 11}},
+		{`tag:"dark mode"`, Filter{Field: FieldTag, Op: OpEq, Value: "dark mode", End: 15}},
+		{"author:@ivan", Filter{Field: FieldAuthor, Op: OpEq, Value: "ivan", End: 12}},
+		{"author:ivan", Filter{Field: FieldAuthor, Op: OpEq, Value: "ivan", End: 11}},
+		{"votes:10", Filter{Field: FieldVotes, Op: OpEq, Value: "10", Int: 10, End: 8}},
+		{"votes:=10", Filter{Field: FieldVotes, Op: OpEq, Value: "10", Int: 10, End: 9}},
+		{"votes:>10", Filter{Field: FieldVotes, Op: OpGt, Value: "10", Int: 10, End: 9}},
+		{"votes:>=10", Filter{Field: FieldVotes, Op: OpGte, Value: "10", Int: 10, End: 10}},
+		{"votes:<-2", Filter{Field: FieldVotes, Op: OpLt, Value: "-2", Int: -2, End: 9}},
+		{"votes:<=0", Filter{Field: FieldVotes, Op: OpLte, Value: "0", Int: 0, End: 9}},
+		{"created:2026-01-31", Filter{Field: FieldCreated, Op: OpEq, Value: "2026-01-31", Date: date("2026-01-31"), End: 18}},
+		{"created:>2026-01-01", Filter{Field: FieldCreated, Op: OpGt, Value: "2026-01-01", Date: date("2026-01-01"), End: 19}},
+		{"created:>=2026-01-01", Filter{Field: FieldCreated, Op: OpGte, Value: "2026-01-01", Date: date("2026-01-01"), End: 20}},
+		{"created:<2026-01-01", Filter{Field: FieldCreated, Op: OpLt, Value: "2026-01-01", Date: date("2026-01-01"), End: 19}},
+		{"created:<=2026-01-01", Filter{Field: FieldCreated, Op: OpLte, Value: "2026-01-01", Date: date("2026-01-01"), End: 20}},
+		// The value runs to the next space, so it may contain a colon
+		{"tag:c:d", Filter{Field: FieldTag, Op: OpEq, Value: "c:d", End: 7}},
+	} {
+		q, err := Parse(tc.input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.input, err)
+			continue
+		}
+		if len(q.Filters) != 1 || len(q.Text) != 0 || !reflect.DeepEqual(q.Filters[0], tc.want) {
+			t.Errorf("Parse(%q) = %+v, want filter %+v", tc.input, q, tc.want)
+		}
+	}
+}
+
+func TestParseText(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  []Text
+	}{
+		{"crash on start", []Text{{"crash", false, 0, 5}, {"on", false, 6, 8}, {"start", false, 9, 14}}},
+		{`"crash on start"`, []Text{{"crash on start", true, 0, 16}}},
+		{`  " padded "  `, []Text{{"padded", true, 2, 12}}},
+		{`""`, nil},
+		// URLs and words with a colon that are not fields stay text
+		{"https://example.com/a?b=c", []Text{{"https://example.com/a?b=c", false, 0, 25}}},
+		{"see http://x.io/status:open", []Text{{"see", false, 0, 3}, {"http://x.io/status:open", false, 4, 27}}},
+		{"note:later", []Text{{"note:later", false, 0, 10}}},
+		{"Status:open", []Text{{"Status:open", false, 0, 11}}},
+		{"10:30", []Text{{"10:30", false, 0, 5}}},
+	} {
+		q, err := Parse(tc.input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.input, err)
+			continue
+		}
+		if len(q.Filters) != 0 || !reflect.DeepEqual(q.Text, tc.want) {
+			t.Errorf("Parse(%q) = %+v, want text %+v", tc.input, q, tc.want)
+		}
+	}
+}
+
+func TestParseMixed(t *testing.T) {
+	q, err := Parse(`status:open крах "при запуске" tag:android`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Query{
+		Filters: []Filter{
+			{Field: FieldStatus, Op: OpEq, Value: "open", Pos: 0, End: 11},
+			{Field: FieldTag, Op: OpEq, Value: "android", Pos: 31, End: 42},
+		},
+		Text: []Text{{"крах", false, 12, 16}, {"при запуске", true, 17, 30}},
+	}
+	if !reflect.DeepEqual(q, want) {
+		t.Errorf("Parse = %+v, want %+v", q, want)
+	}
+	if got := q.FullText(); got != `крах "при запуске"` {
+		t.Errorf("FullText = %q", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		input    string
+		pos, end int
+	}{
+		{`"crash on`, 0, 9},
+		{`crash "on start`, 6, 15},
+		{`tag:"dark mode`, 4, 14},
+		{"status:", 0, 7},
+		{"status:pending", 7, 14},
+		{"status:>open", 7, 12},
+		{"type:bug", 5, 8},
+		{"tag:>android", 4, 12},
+		{"author:@", 7, 8},
+		{"votes:many", 6, 10},
+		{"votes:>", 0, 7},
+		{"created:yesterday", 8, 17},
+		{"created:2026-13-01", 8, 18},
+		// Positions count characters, not bytes
+		{`ошибка "при запуске`, 7, 19},
+		{"ошибка status:закрыт", 14, 20},
+		{"ошибка votes:>много", 13, 19},
+	} {
+		_, err := Parse(tc.input)
+		serr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("Parse(%q) error = %v, want a *SyntaxError", tc.input, err)
+			continue
+		}
+		if serr.Pos != tc.pos || serr.End != tc.end {
+			t.Errorf("Parse(%q) error %q at %d-%d, want %d-%d", tc.input, serr.Message, serr.Pos, serr.End, tc.pos, tc.end)
+		}
+	}
+}
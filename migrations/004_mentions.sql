@@ -0,0 +1,13 @@
+-- Mentions of users (@username) in card descriptions and comments
+CREATE TABLE IF NOT EXISTS mentions (
+    id SERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    card_id INTEGER NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+    comment_id INTEGER REFERENCES comments(id) ON DELETE CASCADE,
+    author_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_mentions_unique ON mentions(user_id, card_id, COALESCE(comment_id, 0));
+CREATE INDEX IF NOT EXISTS idx_mentions_user ON mentions(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_users_username_lower ON users(LOWER(username));
@@ -0,0 +1,4 @@
+-- Staff-only internal comments
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS is_internal BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_comments_user_created ON comments(user_id, created_at);
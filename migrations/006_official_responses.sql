@@ -0,0 +1,4 @@
+-- Comments marked by staff as the official response on a card
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS is_official BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_comments_official ON comments(card_id) WHERE is_official;
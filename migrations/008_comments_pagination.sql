@@ -0,0 +1,2 @@
+-- Cursor pagination over a card's comments
+CREATE INDEX IF NOT EXISTS idx_comments_card_id ON comments(card_id, id);
@@ -0,0 +1,50 @@
+-- Precomputed ranking scores for the hot, best, controversial and most_discussed sorts
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS hot_score DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS best_score DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS controversy_score DOUBLE PRECISION NOT NULL DEFAULT 0;
+-- Number of public (non-internal) comments
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS comment_count INTEGER NOT NULL DEFAULT 0;
+
+-- Vote score with time decay: every 10x of net votes is worth 12.5 hours of age
+CREATE OR REPLACE FUNCTION card_hot_score(likes BIGINT, dislikes BIGINT, created_at TIMESTAMP WITH TIME ZONE)
+RETURNS DOUBLE PRECISION AS $$
+    SELECT (SIGN(likes - dislikes) * LOG(GREATEST(ABS(likes - dislikes), 1)) + EXTRACT(EPOCH FROM created_at) / 45000)::DOUBLE PRECISION
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- Lower bound of the Wilson score interval (95% confidence) for the share of likes
+CREATE OR REPLACE FUNCTION card_best_score(likes BIGINT, dislikes BIGINT)
+RETURNS DOUBLE PRECISION AS $$
+    SELECT CASE WHEN likes + dislikes = 0 THEN 0::DOUBLE PRECISION ELSE
+        ((likes + 1.9208) / (likes + dislikes)::DOUBLE PRECISION
+         - 1.96 * SQRT(likes * dislikes / (likes + dislikes)::DOUBLE PRECISION + 0.9604) / (likes + dislikes))
+        / (1 + 3.8416 / (likes + dislikes))
+    END
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- Many votes split evenly between likes and dislikes rank highest
+CREATE OR REPLACE FUNCTION card_controversy_score(likes BIGINT, dislikes BIGINT)
+RETURNS DOUBLE PRECISION AS $$
+    SELECT CASE WHEN likes = 0 OR dislikes = 0 THEN 0::DOUBLE PRECISION ELSE
+        POWER((likes + dislikes)::DOUBLE PRECISION, LEAST(likes, dislikes)::DOUBLE PRECISION / GREATEST(likes, dislikes))
+    END
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- Backfill cards that were never scored (hot_score is always positive once computed)
+UPDATE cards c SET
+    hot_score = card_hot_score(v.likes, v.dislikes, c.created_at),
+    best_score = card_best_score(v.likes, v.dislikes),
+    controversy_score = card_controversy_score(v.likes, v.dislikes),
+    comment_count = (SELECT COUNT(*) FROM comments WHERE card_id = c.id AND NOT is_internal)
+FROM (
+    SELECT cards.id,
+           COUNT(votes.value) FILTER (WHERE votes.value = 1) AS likes,
+           COUNT(votes.value) FILTER (WHERE votes.value = -1) AS dislikes
+    FROM cards LEFT JOIN votes ON votes.card_id = cards.id
+    GROUP BY cards.id
+) v
+WHERE v.id = c.id AND c.hot_score = 0;
+
+CREATE INDEX IF NOT EXISTS idx_cards_hot ON cards(hot_score DESC);
+CREATE INDEX IF NOT EXISTS idx_cards_best ON cards(best_score DESC, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_cards_controversy ON cards(controversy_score DESC, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_cards_comment_count ON cards(comment_count DESC, created_at DESC);
@@ -0,0 +1,7 @@
+-- Suspicious vote detection: flagged and voided votes are excluded from rating and scores
+ALTER TABLE votes ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'valid'; -- valid, flagged, voided
+ALTER TABLE votes ADD COLUMN IF NOT EXISTS flag_reason TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_votes_review ON votes(status, created_at DESC) WHERE status <> 'valid';
+CREATE INDEX IF NOT EXISTS idx_votes_card_created ON votes(card_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_votes_user_created ON votes(user_id, created_at);
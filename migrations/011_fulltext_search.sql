@@ -0,0 +1,10 @@
+-- Full-text search over card titles (weight A) and descriptions (weight B)
+-- in both the Russian and English configurations
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('russian'::regconfig, COALESCE(title, '')), 'A') ||
+    setweight(to_tsvector('english'::regconfig, COALESCE(title, '')), 'A') ||
+    setweight(to_tsvector('russian'::regconfig, COALESCE(description, '')), 'B') ||
+    setweight(to_tsvector('english'::regconfig, COALESCE(description, '')), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_cards_search ON cards USING GIN(search_vector);
@@ -0,0 +1,4 @@
+-- Trigram similarity for duplicate detection on card titles
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_cards_title_trgm ON cards USING GIN(title gin_trgm_ops);
@@ -0,0 +1,18 @@
+-- Saved card list filters ("views"), shareable by link and publishable by admins
+CREATE TABLE IF NOT EXISTS saved_views (
+    id SERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    sort VARCHAR(50) NOT NULL DEFAULT 'rate',
+    type VARCHAR(50) NOT NULL DEFAULT '',
+    status VARCHAR(50) NOT NULL DEFAULT '',
+    query TEXT NOT NULL DEFAULT '',
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    share_token VARCHAR(64) NOT NULL UNIQUE,
+    is_public BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_saved_views_user ON saved_views(user_id);
+CREATE INDEX IF NOT EXISTS idx_saved_views_public ON saved_views(is_public) WHERE is_public;
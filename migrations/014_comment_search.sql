@@ -0,0 +1,7 @@
+-- Full-text search over comment contents, same configurations as cards
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    to_tsvector('russian'::regconfig, COALESCE(content, '')) ||
+    to_tsvector('english'::regconfig, COALESCE(content, ''))
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_comments_search ON comments USING GIN(search_vector);
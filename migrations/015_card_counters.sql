@@ -0,0 +1,27 @@
+-- Denormalized vote and comment counters on cards, maintained by the repository
+-- in the same transaction as the votes and comments they count.
+-- Backfilled only when the columns are first added; cmd/reconcile repairs drift.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns WHERE table_name = 'cards' AND column_name = 'likes'
+    ) THEN
+        ALTER TABLE cards
+            ADD COLUMN likes INTEGER NOT NULL DEFAULT 0,
+            ADD COLUMN dislikes INTEGER NOT NULL DEFAULT 0,
+            ADD COLUMN internal_comment_count INTEGER NOT NULL DEFAULT 0;
+
+        UPDATE cards c SET
+            likes = v.likes,
+            dislikes = v.dislikes,
+            internal_comment_count = v.internal_comments
+        FROM (
+            SELECT cards.id,
+                   (SELECT COUNT(*) FROM votes WHERE card_id = cards.id AND value = 1 AND status = 'valid') AS likes,
+                   (SELECT COUNT(*) FROM votes WHERE card_id = cards.id AND value = -1 AND status = 'valid') AS dislikes,
+                   (SELECT COUNT(*) FROM comments WHERE card_id = cards.id AND is_internal) AS internal_comments
+            FROM cards
+        ) v
+        WHERE v.id = c.id;
+    END IF;
+END $$;
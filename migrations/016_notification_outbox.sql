@@ -0,0 +1,20 @@
+-- Outbox of Telegram notifications, one row per recipient. Rows are written in the same
+-- transaction as the change they announce and deleted once delivered; failed deliveries
+-- are retried with backoff until they become dead letters (state 'dead').
+CREATE TABLE IF NOT EXISTS notification_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event VARCHAR(20) NOT NULL,
+    card_id INTEGER NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+    comment_id INTEGER REFERENCES comments(id) ON DELETE CASCADE,
+    actor_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    card_status VARCHAR(50),
+    state VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (state IN ('pending', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_outbox_due ON notification_outbox(next_attempt_at) WHERE state = 'pending';
+CREATE INDEX IF NOT EXISTS idx_notification_outbox_state ON notification_outbox(state, created_at DESC);
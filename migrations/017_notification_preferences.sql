@@ -0,0 +1,12 @@
+-- Which notifications a user gets and when. Without a row every notification is sent
+-- at once. Quiet hours are in the user's timezone; notifications due in them wait
+-- until they end.
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    quiet_start TIME,
+    quiet_end TIME,
+    -- 'event:channel' pairs the user turned off, e.g. 'comment:telegram'
+    muted TEXT[] NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
@@ -0,0 +1,16 @@
+-- The card list is ordered by its sort key and then by id, also for keyset cursors
+-- ((key, id) < (last key, last id)); these indexes serve both orders without a sort
+CREATE INDEX IF NOT EXISTS idx_cards_rating_id ON cards(rating DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_cards_created_id ON cards(created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_cards_hot_id ON cards(hot_score DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_cards_best_id ON cards(best_score DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_cards_controversy_id ON cards(controversy_score DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_cards_comment_count_id ON cards(comment_count DESC, id DESC);
+
+-- Superseded by the indexes above
+DROP INDEX IF EXISTS idx_cards_rating;
+DROP INDEX IF EXISTS idx_cards_created;
+DROP INDEX IF EXISTS idx_cards_hot;
+DROP INDEX IF EXISTS idx_cards_best;
+DROP INDEX IF EXISTS idx_cards_controversy;
+DROP INDEX IF EXISTS idx_cards_comment_count;
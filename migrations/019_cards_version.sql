@@ -0,0 +1,9 @@
+-- The version of the card lists. Every transaction that changes cards, or anything shown
+-- with them, advances it as its last statement (see repository.CardsVersion). The latest
+-- updated_at would not do: NOW() is the start of a transaction, so one that commits after
+-- a later one leaves the latest updated_at as it was.
+CREATE TABLE IF NOT EXISTS cards_version (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    version BIGINT NOT NULL DEFAULT 0
+);
+INSERT INTO cards_version (id) VALUES (TRUE) ON CONFLICT DO NOTHING;